@@ -0,0 +1,172 @@
+package gopa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Authorization is the result of Authorize
+type Authorization struct {
+	Allowed bool
+	// Reasons are the reasons or violations returned by
+	// the policy, usually why it's not allowed
+	Reasons    []string
+	DecisionID string
+	// Err is the error that made the decision fail, like OPA
+	// being unreachable or the document being undefined (ErrUndefined).
+	// When it's not nil the Allowed depends on WithFailOpen
+	Err error
+}
+
+// authorizeOptions are the options of Authorize
+type authorizeOptions struct {
+	failOpen bool
+	request  []RequestOptionFunc
+}
+
+// AuthorizeOptionFunc is a type used to configure the Authorize
+type AuthorizeOptionFunc func(*authorizeOptions)
+
+// WithFailOpen allows the request when the decision fails,
+// by default it's denied
+func WithFailOpen() AuthorizeOptionFunc {
+	return func(o *authorizeOptions) {
+		o.failOpen = true
+	}
+}
+
+// WithRequestOptions sets the opts of the request to OPA,
+// like WithMetrics or WithDecodeMode
+func WithRequestOptions(opts ...RequestOptionFunc) AuthorizeOptionFunc {
+	return func(o *authorizeOptions) {
+		o.request = append(o.request, opts...)
+	}
+}
+
+// Names of the keys read from the objects returned by the policies
+var (
+	allowKeys   = []string{"allow", "allowed"}
+	reasonKeys  = []string{"reasons", "violations", "deny"}
+	messageKeys = []string{"msg", "message"}
+)
+
+// Authorize evaluates the document on the path with the input, which
+// accepts the same values as the one of DataGetWithInput, and returns if
+// it's allowed. The document can be:
+//   - a boolean, like an 'allow' rule
+//   - a set or array of violations, like a 'deny[msg]' rule, which is
+//     allowed if it's empty
+//   - an object with an 'allow' or 'allowed' boolean and 'reasons',
+//     'violations' or 'deny' as a set or array. Without boolean
+//     it's allowed if there are no violations, and without any of
+//     the keys it's an unexpected result
+//
+// The violations that are objects use their 'msg' or 'message' as
+// reason. If the decision fails, because of an error of OPA, the
+// document being undefined or not having one of the expected types,
+// the Err is set and it's denied unless WithFailOpen is used
+func Authorize(ctx context.Context, s Service, path string, input interface{}, opts ...AuthorizeOptionFunc) *Authorization {
+	var o authorizeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	ropts := append(o.request[:len(o.request):len(o.request)], WithStrictUndefined())
+	d, err := Decide[interface{}](ctx, s, path, input, ropts...)
+	if err != nil {
+		return &Authorization{Allowed: o.failOpen, Err: err}
+	}
+
+	a := &Authorization{DecisionID: d.DecisionID}
+	a.Allowed, a.Reasons, err = authorization(d.Result)
+	if err != nil {
+		return &Authorization{Allowed: o.failOpen, DecisionID: d.DecisionID, Err: fmt.Errorf("invalid decision on %q: %w", path, err)}
+	}
+
+	return a
+}
+
+// authorization returns if the result
+// is allowed and the reasons it has
+func authorization(result interface{}) (bool, []string, error) {
+	switch r := result.(type) {
+	case bool:
+		return r, nil, nil
+	case []interface{}:
+		reasons, err := authorizationReasons(r)
+		return len(reasons) == 0, reasons, err
+	case map[string]interface{}:
+		var (
+			reasons    []string
+			hasReasons bool
+			err        error
+		)
+		for _, k := range reasonKeys {
+			if v, ok := r[k]; ok {
+				rs, ok := v.([]interface{})
+				if !ok {
+					return false, nil, fmt.Errorf("%q is a %T instead of a set or array", k, v)
+				}
+				if reasons, err = authorizationReasons(rs); err != nil {
+					return false, nil, err
+				}
+				hasReasons = true
+				break
+			}
+		}
+
+		for _, k := range allowKeys {
+			if v, ok := r[k]; ok {
+				allowed, ok := v.(bool)
+				if !ok {
+					return false, nil, fmt.Errorf("%q is a %T instead of a boolean", k, v)
+				}
+				return allowed, reasons, nil
+			}
+		}
+
+		// An object without any of the keys, like a whole
+		// package, is not an authorization decision
+		if hasReasons {
+			return len(reasons) == 0, reasons, nil
+		}
+		return false, nil, fmt.Errorf("unexpected object without any of the keys %q", append(allowKeys[:len(allowKeys):len(allowKeys)], reasonKeys...))
+	}
+
+	return false, nil, fmt.Errorf("unexpected result of type %T", result)
+}
+
+// authorizationReasons converts the rs to strings
+func authorizationReasons(rs []interface{}) ([]string, error) {
+	reasons := make([]string, 0, len(rs))
+	for _, r := range rs {
+		reason, err := authorizationReason(r)
+		if err != nil {
+			return nil, err
+		}
+		reasons = append(reasons, reason)
+	}
+	return reasons, nil
+}
+
+// authorizationReason returns the r if it's a string, the
+// message if it's an object with one or else its JSON
+func authorizationReason(r interface{}) (string, error) {
+	switch v := r.(type) {
+	case string:
+		return v, nil
+	case map[string]interface{}:
+		for _, k := range messageKeys {
+			if msg, ok := v[k].(string); ok {
+				return msg, nil
+			}
+		}
+	}
+
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
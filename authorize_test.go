@@ -0,0 +1,106 @@
+package gopa_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorize(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := gopa.NewEmbeddedClient()
+	require.NoError(t, err)
+
+	_, err = c.PolicyCreateOrUpdate(ctx, "example-authorize", []byte(`
+package opa.authorize
+
+allow { input.user == "alice" }
+
+deny["not an admin"] { input.user != "alice" }
+deny[{"msg": "too late", "code": 2}] { input.late }
+
+result = {"allowed": allow_or_false, "reasons": deny}
+allow_or_false { allow } else = false
+
+violations = {"violations": deny}
+
+invalid = 42
+
+unknown = {"something": 42}
+`))
+	require.NoError(t, err)
+
+	alice := map[string]interface{}{"user": "alice"}
+	bob := map[string]interface{}{"user": "bob", "late": true}
+
+	tests := []struct {
+		name    string
+		path    string
+		input   interface{}
+		allowed bool
+		reasons []string
+	}{
+		{name: "Bool", path: "opa/authorize/allow", input: alice, allowed: true},
+		{name: "SetEmpty", path: "opa/authorize/deny", input: alice, allowed: true, reasons: []string{}},
+		{name: "Set", path: "opa/authorize/deny", input: bob, allowed: false, reasons: []string{"not an admin", "too late"}},
+		{name: "ObjectAllowed", path: "opa/authorize/result", input: alice, allowed: true, reasons: []string{}},
+		{name: "ObjectDenied", path: "opa/authorize/result", input: bob, allowed: false, reasons: []string{"not an admin", "too late"}},
+		{name: "ObjectViolations", path: "opa/authorize/violations", input: bob, allowed: false, reasons: []string{"not an admin", "too late"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := gopa.Authorize(ctx, c, tt.path, tt.input)
+			require.NoError(t, a.Err)
+			assert.Equal(t, tt.allowed, a.Allowed)
+			// The sets have no order
+			assert.ElementsMatch(t, tt.reasons, a.Reasons)
+		})
+	}
+
+	t.Run("Undefined", func(t *testing.T) {
+		a := gopa.Authorize(ctx, c, "opa/authorize/allow", bob)
+		assert.False(t, a.Allowed)
+		assert.True(t, gopa.IsUndefined(a.Err))
+
+		a = gopa.Authorize(ctx, c, "opa/authorize/allow", bob, gopa.WithFailOpen())
+		assert.True(t, a.Allowed)
+		assert.True(t, gopa.IsUndefined(a.Err))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		a := gopa.Authorize(ctx, c, "opa/authorize/invalid", alice)
+		assert.False(t, a.Allowed)
+		assert.EqualError(t, a.Err, `invalid decision on "opa/authorize/invalid": unexpected result of type float64`)
+
+		a = gopa.Authorize(ctx, c, "opa/authorize/unknown", alice)
+		assert.False(t, a.Allowed, "The objects without any of the keys are not allowed")
+		assert.EqualError(t, a.Err, `invalid decision on "opa/authorize/unknown": unexpected object without any of the keys ["allow" "allowed" "reasons" "violations" "deny"]`)
+
+		a = gopa.Authorize(ctx, c, "opa/authorize/unknown", alice, gopa.WithFailOpen())
+		assert.True(t, a.Allowed)
+		assert.Error(t, a.Err)
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		rc, err := gopa.NewClient(gopa.SetURL("http://127.0.0.1:1"))
+		require.NoError(t, err)
+
+		a := gopa.Authorize(ctx, rc, "opa/authorize/allow", alice)
+		assert.False(t, a.Allowed)
+		assert.Error(t, a.Err)
+
+		a = gopa.Authorize(ctx, rc, "opa/authorize/allow", alice, gopa.WithFailOpen())
+		assert.True(t, a.Allowed)
+		assert.Error(t, a.Err)
+	})
+
+	t.Run("RequestOptions", func(t *testing.T) {
+		a := gopa.Authorize(ctx, c, "opa/authorize/allow", alice, gopa.WithRequestOptions(gopa.WithMetrics()))
+		require.NoError(t, a.Err)
+		assert.True(t, a.Allowed)
+	})
+}
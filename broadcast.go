@@ -0,0 +1,312 @@
+package gopa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Broadcaster applies the same writes to several OPA
+// instances, like the sidecars of each pod, so all of
+// them have the same policies and data
+type Broadcaster struct {
+	instances []Service
+	rollback  bool
+}
+
+// BroadcasterOptionFunc is a type used to configure
+// the Broadcaster on initialization time
+type BroadcasterOptionFunc func(*Broadcaster) error
+
+// SetRollback sets if the writes have to be undone on the
+// instances that succeeded when any of the others fails. The
+// data writes fail with a VirtualDocumentError if the path
+// overlaps a policy, as it could not be restored
+func SetRollback(rollback bool) BroadcasterOptionFunc {
+	return func(b *Broadcaster) error {
+		b.rollback = rollback
+		return nil
+	}
+}
+
+// NewBroadcaster initializes a new Broadcaster that
+// writes to all the instances
+func NewBroadcaster(instances []Service, opts ...BroadcasterOptionFunc) (*Broadcaster, error) {
+	if len(instances) == 0 {
+		return nil, errors.New("at least one instance is required")
+	}
+
+	b := &Broadcaster{
+		instances: instances,
+	}
+
+	for _, o := range opts {
+		if err := o(b); err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+// InstanceResult is the result of a write on one of the instances
+type InstanceResult struct {
+	// Index is the position of the instance on the Broadcaster
+	Index int
+	// Err is the error of the write
+	Err error
+	// RolledBack is true when the write succeeded but it
+	// was undone because other instance failed
+	RolledBack bool
+	// RollbackErr is the error undoing the write, the
+	// instance may be inconsistent if it's not nil
+	RollbackErr error
+}
+
+// BroadcastError is the error returned when
+// the write failed on any of the instances
+type BroadcastError struct {
+	Results []InstanceResult
+}
+
+// Error transforms the error into a string with
+// the errors of each instance that failed
+func (e *BroadcastError) Error() string {
+	var (
+		sb     strings.Builder
+		failed int
+	)
+	for _, r := range e.Results {
+		if r.Err != nil {
+			fmt.Fprintf(&sb, "\n\t[%d]: %s", r.Index, r.Err)
+			failed++
+		}
+		if r.RollbackErr != nil {
+			fmt.Fprintf(&sb, "\n\t[%d]: rollback: %s", r.Index, r.RollbackErr)
+		}
+	}
+
+	return fmt.Sprintf("%d of %d instances failed:%s", failed, len(e.Results), sb.String())
+}
+
+// Failed returns the results of the instances that failed
+func (e *BroadcastError) Failed() []InstanceResult {
+	var rs []InstanceResult
+	for _, r := range e.Results {
+		if r.Err != nil {
+			rs = append(rs, r)
+		}
+	}
+	return rs
+}
+
+// PolicyCreateOrUpdate creates or updates the policy with the give id on all the instances
+func (b *Broadcaster) PolicyCreateOrUpdate(ctx context.Context, id string, policy []byte) ([]InstanceResult, error) {
+	return b.broadcast(ctx, func(ctx context.Context, s Service) (undo, error) {
+		return snapshotPolicy(ctx, s, id)
+	}, func(ctx context.Context, s Service) error {
+		_, err := s.PolicyCreateOrUpdate(ctx, id, policy)
+		return err
+	})
+}
+
+// PolicyDelete deletes the policy with the given id on all the instances
+func (b *Broadcaster) PolicyDelete(ctx context.Context, id string) ([]InstanceResult, error) {
+	return b.broadcast(ctx, func(ctx context.Context, s Service) (undo, error) {
+		return snapshotPolicy(ctx, s, id)
+	}, func(ctx context.Context, s Service) error {
+		_, err := s.PolicyDelete(ctx, id)
+		return err
+	})
+}
+
+// DataCreateOrOverride creates or replaces the given data on the path on all the instances
+func (b *Broadcaster) DataCreateOrOverride(ctx context.Context, path string, data interface{}) ([]InstanceResult, error) {
+	data, err := bufferReader(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return b.broadcast(ctx, func(ctx context.Context, s Service) (undo, error) {
+		return snapshotData(ctx, s, path)
+	}, func(ctx context.Context, s Service) error {
+		return s.DataCreateOrOverride(ctx, path, data)
+	})
+}
+
+// DataUpdate applies the patch to the data on the path on all the instances
+func (b *Broadcaster) DataUpdate(ctx context.Context, path string, patch Patch) ([]InstanceResult, error) {
+	return b.broadcast(ctx, func(ctx context.Context, s Service) (undo, error) {
+		return snapshotData(ctx, s, path)
+	}, func(ctx context.Context, s Service) error {
+		return s.DataUpdate(ctx, path, patch)
+	})
+}
+
+// DataDelete deletes the data on the path on all the instances
+func (b *Broadcaster) DataDelete(ctx context.Context, path string) ([]InstanceResult, error) {
+	return b.broadcast(ctx, func(ctx context.Context, s Service) (undo, error) {
+		return snapshotData(ctx, s, path)
+	}, func(ctx context.Context, s Service) error {
+		return s.DataDelete(ctx, path)
+	})
+}
+
+// undo restores an instance to the state before a write
+type undo func(context.Context) error
+
+// broadcast calls the write on all the instances at the same time and,
+// if the rollback is enabled, it first calls the snapshot to know how
+// to undo it. It returns a BroadcastError if any of them failed
+func (b *Broadcaster) broadcast(ctx context.Context, snapshot func(context.Context, Service) (undo, error), write func(context.Context, Service) error) ([]InstanceResult, error) {
+	results := make([]InstanceResult, len(b.instances))
+	undos := make([]undo, len(b.instances))
+
+	var wg sync.WaitGroup
+	for i, s := range b.instances {
+		wg.Add(1)
+		go func(i int, s Service) {
+			defer wg.Done()
+
+			results[i].Index = i
+			if b.rollback {
+				u, err := snapshot(ctx, s)
+				if err != nil {
+					results[i].Err = fmt.Errorf("failed to snapshot before the write: %w", err)
+					return
+				}
+				undos[i] = u
+			}
+
+			results[i].Err = write(ctx, s)
+		}(i, s)
+	}
+	wg.Wait()
+
+	var failed bool
+	for _, r := range results {
+		if r.Err != nil {
+			failed = true
+			break
+		}
+	}
+	if !failed {
+		return results, nil
+	}
+
+	if b.rollback {
+		for i := range results {
+			if results[i].Err != nil {
+				continue
+			}
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i].RollbackErr = undos[i](ctx)
+				results[i].RolledBack = results[i].RollbackErr == nil
+			}(i)
+		}
+		wg.Wait()
+	}
+
+	return results, &BroadcastError{Results: results}
+}
+
+// snapshotPolicy returns how to restore the policy
+// with the id to the current content on s
+func snapshotPolicy(ctx context.Context, s Service, id string) (undo, error) {
+	res, err := s.PolicyGet(ctx, id)
+	if err != nil && !IsNotFound(err) {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		if res == nil {
+			_, err := s.PolicyDelete(ctx, id)
+			if IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		_, err := s.PolicyCreateOrUpdate(ctx, id, []byte(res.Result.Raw))
+		return err
+	}, nil
+}
+
+// VirtualDocumentError is the error returned when the data on
+// the Path can not be restored because it has documents defined
+// by the policy with the PolicyID. The DataGet returns them mixed
+// with the base documents, so writing them back would store the
+// result of the policy as data
+type VirtualDocumentError struct {
+	Path     string
+	PolicyID string
+}
+
+// Error transforms the error into a string
+func (e *VirtualDocumentError) Error() string {
+	return fmt.Sprintf("the data on %q can not be restored as it overlaps the policy %q", e.Path, e.PolicyID)
+}
+
+// snapshotData returns how to restore the data on the path p to
+// the current content on s. It fails with a VirtualDocumentError
+// if the path overlaps the package of any policy
+func snapshotData(ctx context.Context, s Service, p string) (undo, error) {
+	if err := checkBaseDocument(ctx, s, p); err != nil {
+		return nil, err
+	}
+
+	res, err := s.DataGet(ctx, p, WithDecodeMode(DecodeUseNumber))
+	if err != nil {
+		return nil, err
+	}
+
+	return func(ctx context.Context) error {
+		return restoreData(ctx, s, p, res.Result)
+	}, nil
+}
+
+// checkBaseDocument checks that no policy of s has a package
+// on the path p, or inside it, so it has only base documents
+func checkBaseDocument(ctx context.Context, s Service, p string) error {
+	ref, err := dataRef(p)
+	if err != nil {
+		return err
+	}
+	rp := refPath(ref)
+
+	pl, err := s.PolicyList(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, pol := range pl.Result {
+		mod, err := parseModule(pol.ID, []byte(pol.Raw))
+		if err != nil {
+			return err
+		}
+
+		pkg := refPath(mod.Package.Path)
+		if hasPathPrefix(rp, pkg) || hasPathPrefix(pkg, rp) {
+			return &VirtualDocumentError{Path: p, PolicyID: pol.ID}
+		}
+	}
+
+	return nil
+}
+
+// restoreData sets the value v on the path p of s, if v
+// is nil the data on the path is deleted
+func restoreData(ctx context.Context, s Service, p string, v *interface{}) error {
+	if v == nil {
+		err := s.DataDelete(ctx, p)
+		if IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+
+	return s.DataCreateOrOverride(ctx, p, *v)
+}
@@ -0,0 +1,193 @@
+package gopa_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBroadcaster(t *testing.T) {
+	ctx := context.Background()
+
+	newInstances := func(t *testing.T, n int) []gopa.Service {
+		instances := make([]gopa.Service, 0, n)
+		for i := 0; i < n; i++ {
+			c, err := gopa.NewEmbeddedClient()
+			require.NoError(t, err)
+			instances = append(instances, c)
+		}
+		return instances
+	}
+
+	policyID := "example-broadcast"
+	policy := []byte("package opa.broadcast\n\nallow = true\n")
+
+	t.Run("Success", func(t *testing.T) {
+		instances := newInstances(t, 3)
+		b, err := gopa.NewBroadcaster(instances)
+		require.NoError(t, err)
+
+		rs, err := b.PolicyCreateOrUpdate(ctx, policyID, policy)
+		require.NoError(t, err)
+		require.Len(t, rs, 3)
+
+		rs, err = b.DataCreateOrOverride(ctx, "servers", map[string]interface{}{"web": map[string]interface{}{"port": 80}})
+		require.NoError(t, err)
+
+		rs, err = b.DataUpdate(ctx, "servers", gopa.Patch{}.Replace(gopa.PatchPath("web", "port"), 8080))
+		require.NoError(t, err)
+
+		for i, s := range instances {
+			assert.Equal(t, i, rs[i].Index)
+			assert.NoError(t, rs[i].Err)
+
+			res, err := s.DataGet(ctx, "servers/web/port")
+			require.NoError(t, err)
+			assert.Equal(t, float64(8080), *res.Result)
+
+			res, err = s.DataGet(ctx, "opa/broadcast/allow")
+			require.NoError(t, err)
+			assert.Equal(t, true, *res.Result)
+		}
+
+		_, err = b.DataDelete(ctx, "servers")
+		require.NoError(t, err)
+
+		_, err = b.PolicyDelete(ctx, policyID)
+		require.NoError(t, err)
+
+		for _, s := range instances {
+			_, err := s.PolicyGet(ctx, policyID)
+			assert.True(t, gopa.IsNotFound(err))
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		instances := newInstances(t, 3)
+		b, err := gopa.NewBroadcaster(instances)
+		require.NoError(t, err)
+
+		// The last one has a conflicting rule
+		_, err = instances[2].PolicyCreateOrUpdate(ctx, "example-conflict", []byte("package opa.broadcast\n\nallow[x] { x := 1 }\n"))
+		require.NoError(t, err)
+
+		rs, err := b.PolicyCreateOrUpdate(ctx, policyID, policy)
+		require.Error(t, err)
+
+		var bErr *gopa.BroadcastError
+		require.True(t, errors.As(err, &bErr))
+		assert.Equal(t, rs, bErr.Results)
+		require.Len(t, bErr.Failed(), 1)
+		assert.Equal(t, 2, bErr.Failed()[0].Index)
+		assert.True(t, gopa.IsCompileError(bErr.Failed()[0].Err))
+		assert.Contains(t, err.Error(), "1 of 3 instances failed:\n\t[2]: ")
+
+		// Without rollback the others keep the change
+		for _, s := range instances[:2] {
+			_, err := s.PolicyGet(ctx, policyID)
+			assert.NoError(t, err)
+		}
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		instances := newInstances(t, 3)
+		b, err := gopa.NewBroadcaster(instances, gopa.SetRollback(true))
+		require.NoError(t, err)
+
+		_, err = instances[2].PolicyCreateOrUpdate(ctx, "example-conflict", []byte("package opa.broadcast\n\nallow[x] { x := 1 }\n"))
+		require.NoError(t, err)
+
+		rs, err := b.PolicyCreateOrUpdate(ctx, policyID, policy)
+		require.Error(t, err)
+		assert.True(t, rs[0].RolledBack)
+		assert.True(t, rs[1].RolledBack)
+		assert.False(t, rs[2].RolledBack)
+
+		for _, s := range instances[:2] {
+			_, err := s.PolicyGet(ctx, policyID)
+			assert.True(t, gopa.IsNotFound(err), "The new policy is removed")
+		}
+
+		_, err = b.DataCreateOrOverride(ctx, "servers", map[string]interface{}{
+			"web":  map[string]interface{}{"port": 80},
+			"name": "example",
+		})
+		require.NoError(t, err)
+
+		// The last one does not have the db so removing it fails
+		_, err = b.DataUpdate(ctx, "servers", gopa.Patch{}.Add(gopa.PatchPath("db"), map[string]interface{}{"port": 5432}))
+		require.NoError(t, err)
+		err = instances[2].DataDelete(ctx, "servers/db")
+		require.NoError(t, err)
+
+		rs, err = b.DataUpdate(ctx, "servers", gopa.Patch{}.
+			Replace(gopa.PatchPath("web", "port"), 8080).
+			Remove(gopa.PatchPath("db")))
+		require.Error(t, err)
+		assert.True(t, gopa.IsNotFound(rs[2].Err))
+
+		for _, s := range instances[:2] {
+			res, err := s.DataGet(ctx, "servers")
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{
+				"web":  map[string]interface{}{"port": float64(80)},
+				"db":   map[string]interface{}{"port": float64(5432)},
+				"name": "example",
+			}, *res.Result, "The data is restored")
+		}
+
+		// The values that are not objects are also restored
+		err = instances[2].DataDelete(ctx, "servers/name")
+		require.NoError(t, err)
+
+		rs, err = b.DataDelete(ctx, "servers/name")
+		require.Error(t, err)
+		assert.True(t, rs[0].RolledBack)
+
+		for _, s := range instances[:2] {
+			res, err := s.DataGet(ctx, "servers/name")
+			require.NoError(t, err)
+			require.NotNil(t, res.Result)
+			assert.Equal(t, "example", *res.Result)
+		}
+	})
+
+	t.Run("VirtualDocument", func(t *testing.T) {
+		instances := newInstances(t, 2)
+		b, err := gopa.NewBroadcaster(instances, gopa.SetRollback(true))
+		require.NoError(t, err)
+
+		_, err = b.PolicyCreateOrUpdate(ctx, policyID, policy)
+		require.NoError(t, err)
+
+		// The data on opa/broadcast would have the allow of the policy
+		rs, err := b.DataCreateOrOverride(ctx, "opa", map[string]interface{}{"name": "example"})
+		require.Error(t, err)
+		for _, r := range rs {
+			var vErr *gopa.VirtualDocumentError
+			require.True(t, errors.As(r.Err, &vErr))
+			assert.Equal(t, policyID, vErr.PolicyID)
+		}
+
+		for _, s := range instances {
+			res, err := s.DataGet(ctx, "opa")
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{
+				"broadcast": map[string]interface{}{"allow": true},
+			}, *res.Result, "Nothing is written")
+		}
+
+		// The paths outside of the packages can be restored
+		_, err = b.DataCreateOrOverride(ctx, "opa/servers", map[string]interface{}{"name": "example"})
+		require.NoError(t, err)
+	})
+
+	t.Run("NoInstances", func(t *testing.T) {
+		_, err := gopa.NewBroadcaster(nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,377 @@
+package gopa
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// DependencyCycleError is the error returned when
+// the policies depend on each other
+type DependencyCycleError struct {
+	// IDs are the policies of the cycle, each one depends
+	// on the next one and the last one on the first
+	IDs []string
+}
+
+// Error transforms the error into a string
+func (e *DependencyCycleError) Error() string {
+	return fmt.Sprintf("dependency cycle between the policies: %s -> %s", strings.Join(e.IDs, " -> "), e.IDs[0])
+}
+
+// PolicyOrder parses the policies and returns their IDs sorted
+// so each one is after the ones it depends on. A policy depends
+// on the ones with the functions it calls, directly or through
+// an import, as OPA can not compile it without them
+func PolicyOrder(policies map[string][]byte) ([]string, error) {
+	modules, err := parsePolicies(policies)
+	if err != nil {
+		return nil, err
+	}
+
+	return sortModules(modules)
+}
+
+// UploadPolicies uploads all the policies in the PolicyOrder so
+// the dependencies are always created before. Before touching s it
+// checks that there are no cycles and that the policies compile with
+// the ones already on s, so the undefined references are reported
+// as an APIError. It returns the IDs uploaded, which are all of them
+// unless it fails while uploading
+func UploadPolicies(ctx context.Context, s Service, policies map[string][]byte) ([]string, error) {
+	modules, err := parsePolicies(policies)
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := sortModules(modules)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkPolicies(ctx, s, modules, policies, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return putPolicies(ctx, s, order, policies)
+}
+
+// DeletePolicies deletes the policies with the ids, the ones that depend
+// on the others first. Before touching s it checks that the rest of the
+// policies compile without them, so none of them is deleted if any other
+// policy depends on them. It returns the IDs deleted
+func DeletePolicies(ctx context.Context, s Service, ids ...string) ([]string, error) {
+	order, err := deleteOrder(ctx, s, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkPolicies(ctx, s, nil, nil, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	return deletePolicies(ctx, s, order)
+}
+
+// parsePolicies parses all the policies
+func parsePolicies(policies map[string][]byte) (map[string]*ast.Module, error) {
+	modules := make(map[string]*ast.Module, len(policies))
+	for id, b := range policies {
+		mod, err := parseModule(id, b)
+		if err != nil {
+			return nil, err
+		}
+		modules[id] = mod
+	}
+
+	return modules, nil
+}
+
+// sortModules returns the IDs of the modules sorted by
+// dependencies or a DependencyCycleError
+func sortModules(modules map[string]*ast.Module) ([]string, error) {
+	deps := dependencies(modules)
+
+	ids := make([]string, 0, len(modules))
+	for id := range modules {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	const (
+		visiting = iota + 1
+		visited
+	)
+
+	var (
+		order []string
+		stack []string
+		state = make(map[string]int, len(ids))
+		visit func(id string) error
+	)
+	visit = func(id string) error {
+		switch state[id] {
+		case visited:
+			return nil
+		case visiting:
+			for i, sid := range stack {
+				if sid == id {
+					return &DependencyCycleError{IDs: append([]string(nil), stack[i:]...)}
+				}
+			}
+		}
+
+		state[id] = visiting
+		stack = append(stack, id)
+		for _, d := range deps[id] {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		stack = stack[:len(stack)-1]
+		state[id] = visited
+		order = append(order, id)
+
+		return nil
+	}
+
+	for _, id := range ids {
+		if err := visit(id); err != nil {
+			return nil, err
+		}
+	}
+
+	return order, nil
+}
+
+// dependencies returns the sorted IDs of the modules that each
+// module depends on, which are the ones with the functions it calls
+// as OPA fails to compile the calls to undefined functions. The other
+// references are resolved on evaluation, so they don't need an order
+// and can even be mutual. The modules with the same package do not
+// depend on each other as OPA compiles them together
+func dependencies(modules map[string]*ast.Module) map[string][]string {
+	packages := make(map[string][]string, len(modules))
+	for id, mod := range modules {
+		packages[id] = refPath(mod.Package.Path)
+	}
+
+	deps := make(map[string][]string, len(modules))
+	for id, mod := range modules {
+		set := make(map[string]struct{})
+		// The calls are the expressions like 'f(x)'
+		// and the terms like 'y := f(x)'
+		ast.NewGenericVisitor(func(x interface{}) bool {
+			var op ast.Ref
+			switch x := x.(type) {
+			case *ast.Expr:
+				if !x.IsCall() {
+					return false
+				}
+				op = x.Operator()
+			case ast.Call:
+				op, _ = x[0].Value.(ast.Ref)
+			}
+			if op == nil {
+				return false
+			}
+
+			r := resolveImport(op, mod.Imports)
+			if !r.HasPrefix(ast.DefaultRootRef) {
+				return false
+			}
+
+			rp := refPath(r)
+			for did, pkg := range packages {
+				if did == id || equalPath(pkg, packages[id]) {
+					continue
+				}
+				if hasPathPrefix(rp, pkg) {
+					set[did] = struct{}{}
+				}
+			}
+
+			return false
+		}).Walk(mod)
+
+		for did := range set {
+			deps[id] = append(deps[id], did)
+		}
+		sort.Strings(deps[id])
+	}
+
+	return deps
+}
+
+// resolveImport replaces the head of the r with the path
+// of the import it refers to, like data.b.f for b.f
+// with 'import data.b'
+func resolveImport(r ast.Ref, imports []*ast.Import) ast.Ref {
+	head, ok := r[0].Value.(ast.Var)
+	if !ok {
+		return r
+	}
+
+	for _, imp := range imports {
+		ip, ok := imp.Path.Value.(ast.Ref)
+		if !ok || !ip.HasPrefix(ast.DefaultRootRef) {
+			continue
+		}
+
+		name := imp.Alias
+		if name == "" {
+			s, ok := ip[len(ip)-1].Value.(ast.String)
+			if !ok {
+				continue
+			}
+			name = ast.Var(s)
+		}
+
+		if name == head {
+			return ip.Concat(r[1:])
+		}
+	}
+
+	return r
+}
+
+// refPath returns the leading strings of the data ref r,
+// like ["a", "b"] for data.a.b[x].c
+func refPath(r ast.Ref) []string {
+	var p []string
+	for _, t := range r[1:] {
+		s, ok := t.Value.(ast.String)
+		if !ok {
+			break
+		}
+		p = append(p, string(s))
+	}
+	return p
+}
+
+// hasPathPrefix checks if the p starts with the prefix
+func hasPathPrefix(p, prefix []string) bool {
+	return len(p) >= len(prefix) && equalPath(p[:len(prefix)], prefix)
+}
+
+// equalPath checks if a and b are the same path
+func equalPath(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPolicies compiles the policies of s replacing the
+// puts and removing the deletes, as the server will have
+// them at the end. The sources are used on the errors
+func checkPolicies(ctx context.Context, s Service, puts map[string]*ast.Module, sources map[string][]byte, deletes []string) error {
+	pl, err := s.PolicyList(ctx)
+	if err != nil {
+		return err
+	}
+
+	deleted := make(map[string]struct{}, len(deletes))
+	for _, id := range deletes {
+		deleted[id] = struct{}{}
+	}
+
+	modules := make(map[string]*ast.Module, len(pl.Result)+len(puts))
+	for _, p := range pl.Result {
+		if _, ok := deleted[p.ID]; ok {
+			continue
+		}
+		if _, ok := puts[p.ID]; ok {
+			continue
+		}
+		mod, err := parseModule(p.ID, []byte(p.Raw))
+		if err != nil {
+			return err
+		}
+		modules[p.ID] = mod
+	}
+	for id, mod := range puts {
+		modules[id] = mod
+	}
+
+	_, err = compileModules(modules, types.CodeInvalidParameter)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			for id, b := range sources {
+				apiErr.setSource(id, b)
+			}
+		}
+		return err
+	}
+
+	return nil
+}
+
+// deleteOrder returns the ids sorted so the ones that depend
+// on the others are first, using the policies of s
+func deleteOrder(ctx context.Context, s Service, ids []string) ([]string, error) {
+	modules := make(map[string]*ast.Module, len(ids))
+	for _, id := range ids {
+		res, err := s.PolicyGet(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		mod, err := parseModule(id, []byte(res.Result.Raw))
+		if err != nil {
+			return nil, err
+		}
+		modules[id] = mod
+	}
+
+	order, err := sortModules(modules)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, j := 0, len(order)-1; i < j; i, j = i+1, j-1 {
+		order[i], order[j] = order[j], order[i]
+	}
+
+	return order, nil
+}
+
+// putPolicies creates or updates the policies with
+// the ids in order and returns the ones done
+func putPolicies(ctx context.Context, s Service, ids []string, policies map[string][]byte) ([]string, error) {
+	done := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, err := s.PolicyCreateOrUpdate(ctx, id, policies[id]); err != nil {
+			return done, fmt.Errorf("failed to put the policy %q: %w", id, err)
+		}
+		done = append(done, id)
+	}
+
+	return done, nil
+}
+
+// deletePolicies deletes the policies with the
+// ids in order and returns the ones done
+func deletePolicies(ctx context.Context, s Service, ids []string) ([]string, error) {
+	done := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if _, err := s.PolicyDelete(ctx, id); err != nil {
+			return done, fmt.Errorf("failed to delete the policy %q: %w", id, err)
+		}
+		done = append(done, id)
+	}
+
+	return done, nil
+}
@@ -0,0 +1,105 @@
+package gopa_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyOrder(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		order, err := gopa.PolicyOrder(map[string][]byte{
+			"app":   []byte("package app\n\nimport data.authz\n\nallow { authz.check(input.user) }\n"),
+			"authz": []byte("package authz\n\ncheck(u) { x := data.lib.strings.is_admin(u); x }\n"),
+			"lib":   []byte("package lib.strings\n\nis_admin(u) { startswith(u, \"admin\") }\n"),
+			"extra": []byte("package lib.strings\n\nis_root(u) { u == \"root\" }\n"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"extra", "lib", "authz", "app"}, order)
+	})
+
+	t.Run("Cycle", func(t *testing.T) {
+		_, err := gopa.PolicyOrder(map[string][]byte{
+			"a": []byte("package a\n\nimport data.b\n\nx { b.f(1) }\n\ng(v) { v > 0 }\n"),
+			"b": []byte("package b\n\nimport data.c as other\n\nf(v) { other.h(v) }\n"),
+			"c": []byte("package c\n\nh(v) { data.a.g(v) }\n"),
+		})
+		require.Error(t, err)
+
+		var cErr *gopa.DependencyCycleError
+		require.True(t, errors.As(err, &cErr))
+		assert.Equal(t, []string{"a", "b", "c"}, cErr.IDs)
+		assert.Equal(t, "dependency cycle between the policies: a -> b -> c -> a", err.Error())
+	})
+
+	t.Run("MutualReferences", func(t *testing.T) {
+		// Only the function calls need an order, the
+		// other references are resolved on evaluation
+		order, err := gopa.PolicyOrder(map[string][]byte{
+			"a": []byte("package a\n\nx = data.b.y\n\nw = 1\n"),
+			"b": []byte("package b\n\ny = data.a.w\n"),
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, order)
+	})
+
+	t.Run("ParseError", func(t *testing.T) {
+		_, err := gopa.PolicyOrder(map[string][]byte{"a": []byte("potato")})
+		require.Error(t, err)
+		assert.True(t, gopa.IsCompileError(err))
+	})
+}
+
+func TestUploadPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := gopa.NewClient()
+	require.NoError(t, err)
+
+	policies := map[string][]byte{
+		"example-bulk-app": []byte("package bulk.app\n\nallow { data.bulk.lib.is_admin(input.user) }\n"),
+		"example-bulk-lib": []byte("package bulk.lib\n\nis_admin(u) { u == \"admin\" }\n"),
+	}
+
+	t.Run("Unresolved", func(t *testing.T) {
+		_, err := gopa.UploadPolicies(ctx, c, map[string][]byte{
+			"example-bulk-app": policies["example-bulk-app"],
+		})
+		require.Error(t, err)
+		assert.True(t, gopa.IsCompileError(err))
+		assert.Contains(t, err.Error(), "example-bulk-app:3:9: rego_type_error: undefined function data.bulk.lib.is_admin\n\tallow { data.bulk.lib.is_admin(input.user) }")
+
+		_, err = c.PolicyGet(ctx, "example-bulk-app")
+		assert.True(t, gopa.IsNotFound(err), "Nothing is uploaded")
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		done, err := gopa.UploadPolicies(ctx, c, policies)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"example-bulk-lib", "example-bulk-app"}, done)
+
+		res, err := c.DataGetWithInput(ctx, "bulk/app/allow", map[string]interface{}{"user": "admin"})
+		require.NoError(t, err)
+		assert.Equal(t, true, *res.Result)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, err := gopa.DeletePolicies(ctx, c, "example-bulk-lib")
+		require.Error(t, err)
+		assert.True(t, gopa.IsCompileError(err))
+
+		_, err = c.PolicyGet(ctx, "example-bulk-lib")
+		assert.NoError(t, err, "Nothing is deleted")
+
+		done, err := gopa.DeletePolicies(ctx, c, "example-bulk-lib", "example-bulk-app")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"example-bulk-app", "example-bulk-lib"}, done)
+
+		_, err = gopa.DeletePolicies(ctx, c, "example-bulk-lib")
+		assert.True(t, gopa.IsNotFound(err))
+	})
+}
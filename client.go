@@ -5,9 +5,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"path"
+	"strings"
+
+	"github.com/open-policy-agent/opa/server/types"
 )
 
 // Client is the main struct to connect and use OPA
@@ -15,10 +20,17 @@ type Client struct {
 	client *http.Client
 	url    *url.URL
 	token  string
+	retry  *RetryPolicy
+	pool   *endpointPool
+	decode DecodeMode
 
-	policysvc *PolicyService
-	datasvc   *DataService
-	querysvc  *QueryService
+	policysvc  *PolicyService
+	datasvc    *DataService
+	querysvc   *QueryService
+	compilesvc *CompileService
+	healthsvc  *HealthService
+	configsvc  *ConfigService
+	statussvc  *StatusService
 }
 
 // Defaults
@@ -34,9 +46,14 @@ var (
 // on initialization time
 type ClientOptionFunc func(*Client) error
 
-// SetURL sets the u as URL
+// SetURL sets the u as URL, by default DefaultURL. It can not
+// be used with SetEndpoints, which sets several URLs
 func SetURL(u string) ClientOptionFunc {
 	return func(c *Client) error {
+		if c.pool != nil && len(c.pool.endpoints) > 0 {
+			return errSetURLAndEndpoints
+		}
+
 		pu, err := url.Parse(u)
 		if err != nil {
 			return err
@@ -63,6 +80,111 @@ func SetClient(client *http.Client) ClientOptionFunc {
 	}
 }
 
+// RequestOptions are the URL parameters that can be sent
+// on the requests to tune the response of OPA
+type RequestOptions struct {
+	// Explain includes the explanation of the evaluation
+	// with the given mode
+	Explain types.ExplainModeV1
+	// Metrics includes the performance metrics
+	Metrics bool
+	// Instrument includes the detailed performance metrics, it
+	// makes the evaluation slower
+	Instrument bool
+	// Provenance includes the build and version information
+	Provenance bool
+	// StrictBuiltinErrors makes the built-in errors fail the
+	// evaluation instead of being undefined
+	StrictBuiltinErrors bool
+	// Pretty asks OPA to indent the response
+	Pretty bool
+	// Decode is how the result is decoded, it's
+	// not sent to OPA
+	Decode DecodeMode
+	// StrictUndefined returns an UndefinedError when the
+	// document is undefined, it's not sent to OPA
+	StrictUndefined bool
+}
+
+// RequestOptionFunc is a type used to configure
+// the RequestOptions of a request
+type RequestOptionFunc func(*RequestOptions)
+
+// WithExplain sets the explain mode of the request
+func WithExplain(mode types.ExplainModeV1) RequestOptionFunc {
+	return func(o *RequestOptions) {
+		o.Explain = mode
+	}
+}
+
+// WithMetrics includes the metrics on the response
+func WithMetrics() RequestOptionFunc {
+	return func(o *RequestOptions) {
+		o.Metrics = true
+	}
+}
+
+// WithInstrument includes the instrumentation on the response
+func WithInstrument() RequestOptionFunc {
+	return func(o *RequestOptions) {
+		o.Instrument = true
+	}
+}
+
+// WithProvenance includes the provenance on the response
+func WithProvenance() RequestOptionFunc {
+	return func(o *RequestOptions) {
+		o.Provenance = true
+	}
+}
+
+// WithStrictBuiltinErrors makes the built-in errors fail the evaluation
+func WithStrictBuiltinErrors() RequestOptionFunc {
+	return func(o *RequestOptions) {
+		o.StrictBuiltinErrors = true
+	}
+}
+
+// WithPretty asks for an indented response
+func WithPretty() RequestOptionFunc {
+	return func(o *RequestOptions) {
+		o.Pretty = true
+	}
+}
+
+// newRequestOptions applies all the opts to a new RequestOptions
+func newRequestOptions(opts []RequestOptionFunc) RequestOptions {
+	var ro RequestOptions
+	for _, o := range opts {
+		o(&ro)
+	}
+	return ro
+}
+
+// query returns the URL parameters of the options
+func (ro RequestOptions) query() url.Values {
+	q := make(url.Values)
+	if ro.Explain != "" && ro.Explain != types.ExplainOffV1 {
+		q.Set(types.ParamExplainV1, string(ro.Explain))
+	}
+	if ro.Metrics {
+		q.Set(types.ParamMetricsV1, "true")
+	}
+	if ro.Instrument {
+		q.Set(types.ParamInstrumentV1, "true")
+	}
+	if ro.Provenance {
+		q.Set(types.ParamProvenanceV1, "true")
+	}
+	if ro.StrictBuiltinErrors {
+		q.Set("strict-builtin-errors", "true")
+	}
+	if ro.Pretty {
+		q.Set(types.ParamPrettyV1, "true")
+	}
+	return q
+}
+
 // NewClient initializes a new client that can be
 // configured with the opts
 func NewClient(opts ...ClientOptionFunc) (*Client, error) {
@@ -70,20 +192,27 @@ func NewClient(opts ...ClientOptionFunc) (*Client, error) {
 		client: http.DefaultClient,
 	}
 
-	err := SetURL(DefaultURL)(c)
-	if err != nil {
-		return nil, err
-	}
-
 	for _, o := range opts {
 		if err := o(c); err != nil {
 			return nil, err
 		}
 	}
 
+	// The URL is set after the options so SetURL
+	// and SetEndpoints know if the other was used
+	if c.url == nil {
+		if err := SetURL(DefaultURL)(c); err != nil {
+			return nil, err
+		}
+	}
+
 	c.policysvc = NewPolicyService(c)
 	c.datasvc = NewDataService(c)
 	c.querysvc = NewQueryService(c)
+	c.compilesvc = NewCompileService(c)
+	c.healthsvc = NewHealthService(c)
+	c.configsvc = NewConfigService(c)
+	c.statussvc = NewStatusService(c)
 
 	return c, nil
 }
@@ -97,7 +226,9 @@ type APIError struct {
 	Message  string     `json:"message"`
 	Errors   []APIError `json:"errors,omitempty"`
 	Location Location   `json:"location,omitempty"`
-	Details  []string   `json:"details,omitempty"`
+	// Details depends on the error, for example the parse
+	// errors have the line and the index of the error
+	Details interface{} `json:"details,omitempty"`
 }
 
 // Location records a position in source code
@@ -109,41 +240,94 @@ type Location struct {
 	Offset int    `json:"-"`    // The byte offset for the location in the source.
 }
 
-// Error transforms the error into a string
+// String returns the location as 'file:row:col'
+func (l Location) String() string {
+	return fmt.Sprintf("%s:%d:%d", l.File, l.Row, l.Col)
+}
+
+// Error transforms the error into a string. When the error has
+// nested errors, like the compile ones, each of them is in a new
+// line with the location and the source excerpt if present
 func (e *APIError) Error() string {
-	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+	var sb strings.Builder
+
+	if e.Location.Row != 0 {
+		fmt.Fprintf(&sb, "%s: ", e.Location)
+	}
+	fmt.Fprintf(&sb, "%s: %s", e.Code, e.Message)
+
+	if len(e.Location.Text) != 0 {
+		// The caret keeps the tabs of the text so it's aligned
+		caret := make([]byte, 0, e.Location.Col)
+		for i := 0; i < e.Location.Col-1 && i < len(e.Location.Text); i++ {
+			if e.Location.Text[i] == '\t' {
+				caret = append(caret, '\t')
+			} else {
+				caret = append(caret, ' ')
+			}
+		}
+		fmt.Fprintf(&sb, "\n\t%s\n\t%s^", e.Location.Text, caret)
+	}
+
+	for _, ne := range e.Errors {
+		sb.WriteString("\n")
+		sb.WriteString(ne.Error())
+	}
+
+	return sb.String()
+}
+
+// setSource fills the Location.Text of the errors located
+// on the file with the line of the source
+func (e *APIError) setSource(file string, source []byte) {
+	lines := bytes.Split(source, []byte("\n"))
+
+	if e.Location.File == file && e.Location.Row > 0 && e.Location.Row <= len(lines) {
+		e.Location.Text = lines[e.Location.Row-1]
+		e.Location.Offset = 0
+		for _, l := range lines[:e.Location.Row-1] {
+			e.Location.Offset += len(l) + 1
+		}
+		if e.Location.Col > 0 {
+			e.Location.Offset += e.Location.Col - 1
+		}
+	}
+
+	for i := range e.Errors {
+		e.Errors[i].setSource(file, source)
+	}
 }
 
 // do executes the query with the parameters and returns an errors or Decodes the content to the response
 func (c *Client) do(ctx context.Context, method, path string, body []byte, response interface{}) error {
-	req, err := c.request(ctx, method, path, body)
-	if err != nil {
-		return err
-	}
+	return c.doWithQuery(ctx, method, path, nil, body, response)
+}
 
-	res, err := c.client.Do(req)
+// doWithQuery is the same as do but sending the q as URL parameters
+func (c *Client) doWithQuery(ctx context.Context, method, path string, q url.Values, body []byte, response interface{}) error {
+	return c.doReader(ctx, method, path, q, bytes.NewReader(body), DecodeDefault, response)
+}
+
+// doReader is the same as doWithQuery but reading the body from
+// the r, which is only retried if it's an io.Seeker, and decoding
+// the response with the m or the DecodeMode of the Client
+func (c *Client) doReader(ctx context.Context, method, path string, q url.Values, r io.Reader, m DecodeMode, response interface{}) error {
+	res, err := c.send(ctx, method, path, q, r)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 
-	decoder := json.NewDecoder(res.Body)
 	// If the status is not 2XX
-	if res.StatusCode < 200 || res.StatusCode > 300 {
-		var apiErr APIError
-		err = decoder.Decode(&apiErr)
-		if err != nil {
-			return err
-		}
-
-		return &apiErr
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return newResponseError(res)
 	}
 
 	if res.StatusCode == http.StatusNoContent {
 		return nil
 	}
 	// If the status is 2XX
-	err = decoder.Decode(response)
+	err = m.or(c.decode).decode(res.Body, response)
 	if err != nil {
 		return err
 	}
@@ -151,10 +335,133 @@ func (c *Client) do(ctx context.Context, method, path string, body []byte, respo
 	return nil
 }
 
-// request builds a new request
-func (c *Client) request(ctx context.Context, method, path string, body []byte) (*http.Request, error) {
-	buff := bytes.NewBuffer(body)
-	req, err := http.NewRequestWithContext(ctx, method, c.buildURL(path), buff)
+// send sends the request to one of the endpoints and retries
+// it following the RetryPolicy, if the body can be sent again.
+// The caller has to close the body of the response
+func (c *Client) send(ctx context.Context, method, path string, q url.Values, body io.Reader) (*http.Response, error) {
+	var (
+		res *http.Response
+		err error
+	)
+
+	for attempt := 1; ; attempt++ {
+		u := c.url
+		ep := c.pool.pick()
+		if ep != nil {
+			u = ep.url
+		}
+
+		var req *http.Request
+		// The request is built on each attempt so
+		// the body is sent again
+		req, err = c.request(ctx, u, method, path, q, body)
+		if err != nil {
+			return nil, err
+		}
+
+		res, err = c.client.Do(req)
+		c.pool.done(ctx, ep, res, err)
+		if !c.retry.shouldRetry(ctx, method, attempt, res, err) || !rewind(body) || !c.retry.wait(ctx, attempt) {
+			break
+		}
+
+		if err == nil {
+			io.Copy(ioutil.Discard, res.Body)
+			res.Body.Close()
+		}
+	}
+
+	return res, err
+}
+
+// rewind moves the body to the start so it can be
+// sent again, it returns false if it's not possible
+func rewind(body io.Reader) bool {
+	if body == nil {
+		return true
+	}
+
+	s, ok := body.(io.Seeker)
+	if !ok {
+		return false
+	}
+
+	_, err := s.Seek(0, io.SeekStart)
+	return err == nil
+}
+
+// jsonBody returns the body with the JSON of v. The json.RawMessage
+// and the io.Reader are sent as they are, so the latter are streamed
+func jsonBody(v interface{}) (io.Reader, error) {
+	switch b := v.(type) {
+	case io.Reader:
+		return b, nil
+	case json.RawMessage:
+		return bytes.NewReader(b), nil
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(b), nil
+}
+
+// bufferReader reads the v, if it's an io.Reader, to a json.RawMessage
+// so it can be sent more than once
+func bufferReader(v interface{}) (interface{}, error) {
+	r, ok := v.(io.Reader)
+	if !ok {
+		return v, nil
+	}
+
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(b), nil
+}
+
+// inputBody returns the body with the JSON of the input i
+// like {"input": i}, which accepts the same values as jsonBody
+func inputBody(i interface{}) (io.Reader, error) {
+	r, ok := i.(io.Reader)
+	if !ok {
+		return jsonBody(map[string]interface{}{
+			"input": i,
+		})
+	}
+
+	return io.MultiReader(strings.NewReader(`{"input":`), r, strings.NewReader(`}`)), nil
+}
+
+// newResponseError builds the ResponseError of the res
+func newResponseError(res *http.Response) error {
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	resErr := &ResponseError{
+		StatusCode: res.StatusCode,
+		Method:     res.Request.Method,
+		Path:       res.Request.URL.Path,
+		Body:       b,
+	}
+
+	var apiErr APIError
+	if json.Unmarshal(b, &apiErr) == nil && apiErr.Code != "" {
+		resErr.APIError = &apiErr
+	}
+
+	return resErr
+}
+
+// request builds a new request to the base URL u with the query q as URL parameters
+func (c *Client) request(ctx context.Context, u *url.URL, method, path string, q url.Values, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, buildURL(u, path, q), body)
 	if err != nil {
 		return nil, err
 	}
@@ -166,10 +473,13 @@ func (c *Client) request(ctx context.Context, method, path string, body []byte)
 	return req, nil
 }
 
-// buildURL build a URL with the given path p
-func (c *Client) buildURL(p string) string {
-	u := *c.url
-	u.Path = path.Join(c.url.Path, p)
+// buildURL build a URL from the base with the given path p and query q
+func buildURL(base *url.URL, p string, q url.Values) string {
+	u := *base
+	u.Path = path.Join(base.Path, p)
+	if len(q) != 0 {
+		u.RawQuery = q.Encode()
+	}
 	return u.String()
 
 }
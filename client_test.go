@@ -20,6 +20,10 @@ func TestNewClient(t *testing.T) {
 		ec.policysvc = NewPolicyService(ec)
 		ec.datasvc = NewDataService(ec)
 		ec.querysvc = NewQueryService(ec)
+		ec.compilesvc = NewCompileService(ec)
+		ec.healthsvc = NewHealthService(ec)
+		ec.configsvc = NewConfigService(ec)
+		ec.statussvc = NewStatusService(ec)
 
 		c, err := NewClient()
 		require.NoError(t, err)
@@ -40,6 +44,10 @@ func TestSetURL(t *testing.T) {
 		ec.policysvc = NewPolicyService(ec)
 		ec.datasvc = NewDataService(ec)
 		ec.querysvc = NewQueryService(ec)
+		ec.compilesvc = NewCompileService(ec)
+		ec.healthsvc = NewHealthService(ec)
+		ec.configsvc = NewConfigService(ec)
+		ec.statussvc = NewStatusService(ec)
 
 		c, err := NewClient(SetURL("http://google.com"))
 		require.NoError(t, err)
@@ -60,6 +68,10 @@ func TestSetToken(t *testing.T) {
 		ec.policysvc = NewPolicyService(ec)
 		ec.datasvc = NewDataService(ec)
 		ec.querysvc = NewQueryService(ec)
+		ec.compilesvc = NewCompileService(ec)
+		ec.healthsvc = NewHealthService(ec)
+		ec.configsvc = NewConfigService(ec)
+		ec.statussvc = NewStatusService(ec)
 
 		c, err := NewClient(SetToken(token))
 		require.NoError(t, err)
@@ -79,9 +91,37 @@ func TestSetClient(t *testing.T) {
 		ec.policysvc = NewPolicyService(ec)
 		ec.datasvc = NewDataService(ec)
 		ec.querysvc = NewQueryService(ec)
+		ec.compilesvc = NewCompileService(ec)
+		ec.healthsvc = NewHealthService(ec)
+		ec.configsvc = NewConfigService(ec)
+		ec.statussvc = NewStatusService(ec)
 
 		c, err := NewClient(SetClient(cl))
 		require.NoError(t, err)
 		assert.Equal(t, ec, c)
 	})
 }
+
+func TestSetRetryPolicy(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		u, _ := url.Parse(DefaultURL)
+		rp := DefaultRetryPolicy
+		ec := &Client{
+			url:    u,
+			client: http.DefaultClient,
+			retry:  &rp,
+		}
+
+		ec.policysvc = NewPolicyService(ec)
+		ec.datasvc = NewDataService(ec)
+		ec.querysvc = NewQueryService(ec)
+		ec.compilesvc = NewCompileService(ec)
+		ec.healthsvc = NewHealthService(ec)
+		ec.configsvc = NewConfigService(ec)
+		ec.statussvc = NewStatusService(ec)
+
+		c, err := NewClient(SetRetryPolicy(DefaultRetryPolicy))
+		require.NoError(t, err)
+		assert.Equal(t, ec, c)
+	})
+}
@@ -0,0 +1,63 @@
+package gopa
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// CompileService is the service in charge of
+// the Compile (partial evaluation) interactions
+type CompileService struct {
+	client *Client
+	path   string
+}
+
+// NewCompileService initializes a new CompileService
+func NewCompileService(c *Client) *CompileService {
+	return &CompileService{
+		client: c,
+		path:   "/v1/compile",
+	}
+}
+
+// CompileOptions are the options available to the Partial
+type CompileOptions struct {
+	// Query is the query to partially evaluate
+	Query string `json:"query"`
+	// Input is the known input to use during the evaluation
+	// Input can be any value that can be encoded to JSON
+	Input interface{} `json:"input,omitempty"`
+	// Unknowns are the references that will be treated as
+	// unknown, by default OPA uses 'input'
+	Unknowns []string `json:"unknowns,omitempty"`
+}
+
+// CompileResponse models the response of the Compile API
+// with the result already typed
+type CompileResponse struct {
+	Result      *types.PartialEvaluationResultV1 `json:"result,omitempty"`
+	Explanation types.TraceV1                    `json:"explanation,omitempty"`
+	Metrics     types.MetricsV1                  `json:"metrics,omitempty"`
+}
+
+// Partial partially evaluates the query with the given opt and returns
+// the residual queries and support modules
+// https://www.openpolicyagent.org/docs/latest/rest-api/#partially-evaluate-a-query
+func (cs *CompileService) Partial(ctx context.Context, opt CompileOptions) (*CompileResponse, error) {
+	var res CompileResponse
+
+	b, err := json.Marshal(opt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = cs.client.do(ctx, http.MethodPost, cs.path, b, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,69 @@
+package gopa_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileService(t *testing.T) {
+	policyID := "example-compile"
+	policy := []byte(`
+package opa.examples
+
+allow_request { input.subject.clearance_level >= data.reports[_].clearance_level }
+`)
+
+	c, err := gopa.NewClient()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = c.PolicyCreateOrUpdate(ctx, policyID, policy)
+	require.NoError(t, err)
+
+	defer c.PolicyDelete(ctx, policyID)
+
+	t.Run("Partial", func(t *testing.T) {
+		t.Run("Success", func(t *testing.T) {
+			res, err := c.CompilePartial(ctx, gopa.CompileOptions{
+				Query: "data.opa.examples.allow_request == true",
+				Input: map[string]interface{}{
+					"subject": map[string]interface{}{
+						"clearance_level": 4,
+					},
+				},
+				Unknowns: []string{"data.reports"},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, res.Result)
+			require.Len(t, res.Result.Queries, 1)
+			assert.Equal(t, "gte(4, data.reports[_].clearance_level)", res.Result.Queries[0].String())
+		})
+
+		t.Run("Undefined", func(t *testing.T) {
+			res, err := c.CompilePartial(ctx, gopa.CompileOptions{
+				Query: "data.opa.examples.allow_request == true",
+				Input: map[string]interface{}{
+					"subject": map[string]interface{}{
+						"clearance_level": 4,
+					},
+				},
+				Unknowns: []string{"input.potato"},
+			})
+			require.NoError(t, err)
+			require.NotNil(t, res.Result)
+			assert.Empty(t, res.Result.Queries)
+		})
+
+		t.Run("Error", func(t *testing.T) {
+			_, err := c.CompilePartial(ctx, gopa.CompileOptions{
+				Query: "potato ==",
+			})
+			assert.Error(t, err)
+		})
+	})
+}
@@ -0,0 +1,42 @@
+package gopa
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/open-policy-agent/opa/config"
+)
+
+// ConfigService is the service in charge of
+// the Config interactions
+type ConfigService struct {
+	client *Client
+	path   string
+}
+
+// NewConfigService initializes a new ConfigService
+func NewConfigService(c *Client) *ConfigService {
+	return &ConfigService{
+		client: c,
+		path:   "/v1/config",
+	}
+}
+
+// ConfigResponse models the response of the Config API
+type ConfigResponse struct {
+	Result *config.Config `json:"result"`
+}
+
+// Get returns the active configuration of OPA, the
+// credentials are omitted by OPA
+// https://www.openpolicyagent.org/docs/latest/rest-api/#config-api
+func (cs *ConfigService) Get(ctx context.Context) (*ConfigResponse, error) {
+	var res ConfigResponse
+
+	err := cs.client.do(ctx, http.MethodGet, cs.path, noBody, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
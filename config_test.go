@@ -0,0 +1,38 @@
+package gopa_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigService(t *testing.T) {
+	t.Run("Get", func(t *testing.T) {
+		// The Config API is not available on all the
+		// OPA versions so we fake it
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, "/v1/config", r.URL.Path)
+			assert.Equal(t, "Bearer my-token", r.Header.Get("Authorization"))
+			w.Write([]byte(`{"result":{"labels":{"id":"opa-1","version":"0.34.0"},"default_decision":"/system/main","bundles":{"authz":{"service":"acmecorp"}}}}`))
+		}))
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL), gopa.SetToken("my-token"))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		res, err := c.ConfigGet(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, res.Result)
+		assert.Equal(t, map[string]string{"id": "opa-1", "version": "0.34.0"}, res.Result.Labels)
+		assert.Equal(t, "/system/main", *res.Result.DefaultDecision)
+		assert.JSONEq(t, `{"authz":{"service":"acmecorp"}}`, string(res.Result.Bundles))
+	})
+}
@@ -0,0 +1,261 @@
+package gopa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DiffKind is the kind of difference between
+// an instance and the reference one
+type DiffKind string
+
+// List of the possible DiffKind
+const (
+	// DiffMissing is something the reference has
+	// but the instance does not
+	DiffMissing DiffKind = "missing"
+	// DiffExtra is something the instance has
+	// but the reference does not
+	DiffExtra DiffKind = "extra"
+	// DiffChanged is something both have with different content
+	DiffChanged DiffKind = "changed"
+)
+
+// Diff is a difference between an instance and the reference
+type Diff struct {
+	Kind DiffKind
+	// Index is the position of the instance
+	Index int
+	// Path is the ID of the policy or, for the data,
+	// the JSON pointer of the document from the root
+	Path string
+	// Expected is the content on the reference, the
+	// numbers of the data are json.Number
+	Expected interface{}
+	// Actual is the content on the instance
+	Actual interface{}
+}
+
+// String returns the Diff as '[index] kind path'
+func (d Diff) String() string {
+	return fmt.Sprintf("[%d] %s %s", d.Index, d.Kind, d.Path)
+}
+
+// ConsistencyReport is the result of CheckConsistency
+type ConsistencyReport struct {
+	Policies []Diff
+	Data     []Diff
+}
+
+// Consistent returns if all the instances are equal
+func (r *ConsistencyReport) Consistent() bool {
+	return len(r.Policies) == 0 && len(r.Data) == 0
+}
+
+// instanceState is the content of an instance to compare
+type instanceState struct {
+	policies map[string]string
+	data     []*interface{}
+}
+
+// CheckConsistency compares the policies and the data on the dataPaths
+// of all the instances with the first one, the reference, and reports
+// all the differences. It's useful to detect the instances that missed
+// an update
+func CheckConsistency(ctx context.Context, instances []Service, dataPaths ...string) (*ConsistencyReport, error) {
+	if len(instances) == 0 {
+		return nil, errors.New("at least one instance is required")
+	}
+
+	states := make([]*instanceState, len(instances))
+	errs := make([]error, len(instances))
+
+	var wg sync.WaitGroup
+	for i, s := range instances {
+		wg.Add(1)
+		go func(i int, s Service) {
+			defer wg.Done()
+			states[i], errs[i] = getInstanceState(ctx, s, dataPaths)
+		}(i, s)
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to get the instance %d: %w", i, err)
+		}
+	}
+
+	var r ConsistencyReport
+	ref := states[0]
+	for i, st := range states[1:] {
+		i++
+		r.Policies = append(r.Policies, diffPolicies(i, ref.policies, st.policies)...)
+
+		for j, dp := range dataPaths {
+			r.Data = append(r.Data, diffData(i, dataPointer(dp), ref.data[j], st.data[j])...)
+		}
+	}
+
+	return &r, nil
+}
+
+// getInstanceState gets the policies and the dataPaths from s
+func getInstanceState(ctx context.Context, s Service, dataPaths []string) (*instanceState, error) {
+	pl, err := s.PolicyList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	st := &instanceState{
+		policies: make(map[string]string, len(pl.Result)),
+		data:     make([]*interface{}, 0, len(dataPaths)),
+	}
+	for _, p := range pl.Result {
+		st.policies[p.ID] = p.Raw
+	}
+
+	for _, dp := range dataPaths {
+		// The numbers are compared with all the digits
+		res, err := s.DataGet(ctx, dp, WithDecodeMode(DecodeUseNumber))
+		if err != nil {
+			return nil, err
+		}
+		st.data = append(st.data, res.Result)
+	}
+
+	return st, nil
+}
+
+// diffPolicies returns the differences of the policies
+// of the instance i with the ones of the reference
+func diffPolicies(i int, ref, policies map[string]string) []Diff {
+	var diffs []Diff
+	for _, id := range sortedKeys(ref, policies) {
+		rr, inRef := ref[id]
+		r, ok := policies[id]
+		switch {
+		case !ok:
+			diffs = append(diffs, Diff{Kind: DiffMissing, Index: i, Path: id, Expected: rr})
+		case !inRef:
+			diffs = append(diffs, Diff{Kind: DiffExtra, Index: i, Path: id, Actual: r})
+		case rr != r:
+			diffs = append(diffs, Diff{Kind: DiffChanged, Index: i, Path: id, Expected: rr, Actual: r})
+		}
+	}
+	return diffs
+}
+
+// diffData returns the structural differences of the document v of the
+// instance i, on the JSON pointer p, with the one of the reference. The
+// nil values are undefined documents
+func diffData(i int, p string, ref, v *interface{}) []Diff {
+	switch {
+	case ref == nil && v == nil:
+		return nil
+	case v == nil:
+		return []Diff{{Kind: DiffMissing, Index: i, Path: p, Expected: *ref}}
+	case ref == nil:
+		return []Diff{{Kind: DiffExtra, Index: i, Path: p, Actual: *v}}
+	}
+
+	switch rv := (*ref).(type) {
+	case map[string]interface{}:
+		vv, ok := (*v).(map[string]interface{})
+		if !ok {
+			break
+		}
+
+		var diffs []Diff
+		for _, k := range sortedKeys(rv, vv) {
+			diffs = append(diffs, diffData(i, strings.TrimSuffix(p, "/")+"/"+pointerEscaper.Replace(k), value(rv, k), value(vv, k))...)
+		}
+		return diffs
+	case []interface{}:
+		vv, ok := (*v).([]interface{})
+		if !ok {
+			break
+		}
+
+		var diffs []Diff
+		for j := 0; j < len(rv) || j < len(vv); j++ {
+			var re, e *interface{}
+			if j < len(rv) {
+				re = &rv[j]
+			}
+			if j < len(vv) {
+				e = &vv[j]
+			}
+			diffs = append(diffs, diffData(i, strings.TrimSuffix(p, "/")+"/"+strconv.Itoa(j), re, e)...)
+		}
+		return diffs
+	case json.Number:
+		// The same number can be written in different ways
+		if vv, ok := (*v).(json.Number); ok && equalNumber(rv, vv) {
+			return nil
+		}
+	}
+
+	if !reflect.DeepEqual(*ref, *v) {
+		return []Diff{{Kind: DiffChanged, Index: i, Path: p, Expected: *ref, Actual: *v}}
+	}
+
+	return nil
+}
+
+// equalNumber checks if a and b are the same number, like 1 and 1.0
+func equalNumber(a, b json.Number) bool {
+	ra, ok := new(big.Rat).SetString(string(a))
+	if !ok {
+		return a == b
+	}
+	rb, ok := new(big.Rat).SetString(string(b))
+	if !ok {
+		return a == b
+	}
+	return ra.Cmp(rb) == 0
+}
+
+// value returns the value of the key k of m, nil if it's not present
+func value(m map[string]interface{}, k string) *interface{} {
+	v, ok := m[k]
+	if !ok {
+		return nil
+	}
+	return &v
+}
+
+// sortedKeys returns the keys of both maps sorted
+func sortedKeys(a, b interface{}) []string {
+	keys := make(map[string]struct{})
+	for _, m := range []interface{}{a, b} {
+		for _, k := range reflect.ValueOf(m).MapKeys() {
+			keys[k.String()] = struct{}{}
+		}
+	}
+
+	sk := make([]string, 0, len(keys))
+	for k := range keys {
+		sk = append(sk, k)
+	}
+	sort.Strings(sk)
+
+	return sk
+}
+
+// dataPointer returns the JSON pointer of the data path p
+func dataPointer(p string) string {
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return "/"
+	}
+	return PatchPath(strings.Split(p, "/")...)
+}
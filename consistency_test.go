@@ -0,0 +1,119 @@
+package gopa_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckConsistency(t *testing.T) {
+	ctx := context.Background()
+
+	policy := "package opa.consistency\n\nallow = true\n"
+	data := map[string]interface{}{
+		"web": map[string]interface{}{
+			"port":  80,
+			"hosts": []interface{}{"a", "b"},
+		},
+		"db": map[string]interface{}{"port": 5432},
+	}
+
+	instances := make([]gopa.Service, 0, 3)
+	for i := 0; i < 3; i++ {
+		c, err := gopa.NewEmbeddedClient()
+		require.NoError(t, err)
+		_, err = c.PolicyCreateOrUpdate(ctx, "example-consistency", []byte(policy))
+		require.NoError(t, err)
+		err = c.DataCreateOrOverride(ctx, "servers", data)
+		require.NoError(t, err)
+		instances = append(instances, c)
+	}
+
+	t.Run("Consistent", func(t *testing.T) {
+		r, err := gopa.CheckConsistency(ctx, instances, "servers", "potato")
+		require.NoError(t, err)
+		assert.True(t, r.Consistent())
+	})
+
+	t.Run("Inconsistent", func(t *testing.T) {
+		_, err := instances[1].PolicyCreateOrUpdate(ctx, "example-consistency", []byte(policy+"\ndeny = false\n"))
+		require.NoError(t, err)
+		_, err = instances[1].PolicyCreateOrUpdate(ctx, "example-extra", []byte("package opa.extra\n"))
+		require.NoError(t, err)
+		_, err = instances[2].PolicyDelete(ctx, "example-consistency")
+		require.NoError(t, err)
+
+		err = instances[1].DataUpdate(ctx, "servers", gopa.Patch{}.
+			Replace(gopa.PatchPath("web", "port"), 8080).
+			Add(gopa.PatchPath("web", "hosts", "-"), "c").
+			Remove(gopa.PatchPath("db")))
+		require.NoError(t, err)
+		err = instances[2].DataDelete(ctx, "servers")
+		require.NoError(t, err)
+
+		r, err := gopa.CheckConsistency(ctx, instances, "servers")
+		require.NoError(t, err)
+		assert.False(t, r.Consistent())
+
+		assert.Equal(t, []gopa.Diff{
+			{Kind: gopa.DiffChanged, Index: 1, Path: "example-consistency", Expected: policy, Actual: policy + "\ndeny = false\n"},
+			{Kind: gopa.DiffExtra, Index: 1, Path: "example-extra", Actual: "package opa.extra\n"},
+			{Kind: gopa.DiffMissing, Index: 2, Path: "example-consistency", Expected: policy},
+		}, r.Policies)
+
+		assert.Equal(t, []gopa.Diff{
+			{Kind: gopa.DiffMissing, Index: 1, Path: "/servers/db", Expected: map[string]interface{}{"port": json.Number("5432")}},
+			{Kind: gopa.DiffExtra, Index: 1, Path: "/servers/web/hosts/2", Actual: "c"},
+			{Kind: gopa.DiffChanged, Index: 1, Path: "/servers/web/port", Expected: json.Number("80"), Actual: json.Number("8080")},
+		}, r.Data[:3])
+		require.Len(t, r.Data, 4)
+		assert.Equal(t, gopa.DiffMissing, r.Data[3].Kind)
+		assert.Equal(t, "/servers", r.Data[3].Path)
+		assert.Equal(t, "[2] missing /servers", r.Data[3].String())
+	})
+
+	t.Run("BigNumbers", func(t *testing.T) {
+		// 2^53+1 and 2^53 are the same float64
+		a, err := gopa.NewEmbeddedClient()
+		require.NoError(t, err)
+		err = a.DataCreateOrOverride(ctx, "big", json.RawMessage(`{"x":9007199254740993}`))
+		require.NoError(t, err)
+
+		b, err := gopa.NewEmbeddedClient()
+		require.NoError(t, err)
+		err = b.DataCreateOrOverride(ctx, "big", json.RawMessage(`{"x":9007199254740992}`))
+		require.NoError(t, err)
+
+		r, err := gopa.CheckConsistency(ctx, []gopa.Service{a, b}, "big")
+		require.NoError(t, err)
+		assert.False(t, r.Consistent())
+		assert.Equal(t, []gopa.Diff{
+			{Kind: gopa.DiffChanged, Index: 1, Path: "/big/x", Expected: json.Number("9007199254740993"), Actual: json.Number("9007199254740992")},
+		}, r.Data)
+	})
+
+	t.Run("SameNumbers", func(t *testing.T) {
+		a, err := gopa.NewEmbeddedClient()
+		require.NoError(t, err)
+		err = a.DataCreateOrOverride(ctx, "numbers", json.RawMessage(`{"x":1.0,"y":1e2,"z":[0.50]}`))
+		require.NoError(t, err)
+
+		b, err := gopa.NewEmbeddedClient()
+		require.NoError(t, err)
+		err = b.DataCreateOrOverride(ctx, "numbers", json.RawMessage(`{"x":1,"y":100,"z":[0.5]}`))
+		require.NoError(t, err)
+
+		r, err := gopa.CheckConsistency(ctx, []gopa.Service{a, b}, "numbers")
+		require.NoError(t, err)
+		assert.True(t, r.Consistent(), "The numbers are compared by value: %v", r.Data)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := gopa.CheckConsistency(ctx, nil)
+		assert.Error(t, err)
+	})
+}
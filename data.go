@@ -1,6 +1,7 @@
 package gopa
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"net/http"
@@ -24,17 +25,19 @@ func NewDataService(c *Client) *DataService {
 	}
 }
 
-// CreateOrOverride creates or replaces the given data on the path p
+// CreateOrOverride creates or replaces the given data on the path p. The
+// data can be any value that can be encoded to JSON, a json.RawMessage
+// or an io.Reader with the JSON, which is streamed to OPA
 // https://www.openpolicyagent.org/docs/latest/rest-api/#create-or-overwrite-a-document
-func (ds *DataService) CreateOrOverride(ctx context.Context, p string, data map[string]interface{}) error {
+func (ds *DataService) CreateOrOverride(ctx context.Context, p string, data interface{}) error {
 	var res interface{}
 
-	b, err := json.Marshal(data)
+	body, err := jsonBody(data)
 	if err != nil {
 		return err
 	}
 
-	err = ds.client.do(ctx, http.MethodPut, path.Join(ds.path, p), b, &res)
+	err = ds.client.doReader(ctx, http.MethodPut, path.Join(ds.path, p), nil, body, DecodeDefault, &res)
 	if err != nil {
 		return err
 	}
@@ -42,52 +45,63 @@ func (ds *DataService) CreateOrOverride(ctx context.Context, p string, data map[
 	return nil
 }
 
-// Get get's the data on the given path p
+// Get get's the data on the given path p, the opts can be used
+// to ask for explanations, metrics or provenance or to fail if
+// it's undefined with WithStrictUndefined
 // https://www.openpolicyagent.org/docs/latest/rest-api/#get-a-document
-func (ds *DataService) Get(ctx context.Context, p string) (*types.DataResponseV1, error) {
-	var res types.DataResponseV1
+func (ds *DataService) Get(ctx context.Context, p string, opts ...RequestOptionFunc) (*types.DataResponseV1, error) {
+	var res dataResponse
 
-	err := ds.client.do(ctx, http.MethodGet, path.Join(ds.path, p), noBody, &res)
+	ro := newRequestOptions(opts)
+	err := ds.client.doReader(ctx, http.MethodGet, path.Join(ds.path, p), ro.query(), bytes.NewReader(noBody), ro.Decode, &res)
 	if err != nil {
 		return nil, err
 	}
 
-	return &res, nil
+	if err := res.undefined(p, ro); err != nil {
+		return nil, err
+	}
+
+	return &res.DataResponseV1, nil
 }
 
-// GetWithInput get's the data on the given path p with the input i
+// GetWithInput get's the data on the given path p with the input i, which accepts
+// the same values as the data of CreateOrOverride. The opts can be used to ask
+// for explanations, metrics or provenance or to fail if it's undefined
 // https://www.openpolicyagent.org/docs/latest/rest-api/#get-a-document-with-input
-func (ds *DataService) GetWithInput(ctx context.Context, p string, i map[string]interface{}) (*types.DataResponseV1, error) {
-	var res types.DataResponseV1
+func (ds *DataService) GetWithInput(ctx context.Context, p string, i interface{}, opts ...RequestOptionFunc) (*types.DataResponseV1, error) {
+	var res dataResponse
 
-	input := map[string]interface{}{
-		"input": i,
-	}
-	b, err := json.Marshal(input)
+	body, err := inputBody(i)
 	if err != nil {
 		return nil, err
 	}
 
-	err = ds.client.do(ctx, http.MethodPost, path.Join(ds.path, p), b, &res)
+	ro := newRequestOptions(opts)
+	err = ds.client.doReader(ctx, http.MethodPost, path.Join(ds.path, p), ro.query(), body, ro.Decode, &res)
 	if err != nil {
 		return nil, err
 	}
 
-	return &res, nil
+	if err := res.undefined(p, ro); err != nil {
+		return nil, err
+	}
+
+	return &res.DataResponseV1, nil
 }
 
-// Update updates the data on the given path p. Can be used to do partial updates
-// by using the path to specify the element
+// Update applies the patch to the data on the given path p, so only
+// the elements on the patch are changed
 // https://www.openpolicyagent.org/docs/latest/rest-api/#patch-a-document
-func (ds *DataService) Update(ctx context.Context, p string, data map[string]interface{}) error {
+func (ds *DataService) Update(ctx context.Context, p string, patch Patch) error {
 	var res interface{}
 
-	b, err := json.Marshal(data)
+	b, err := json.Marshal(patch)
 	if err != nil {
 		return err
 	}
 
-	err = ds.client.do(ctx, http.MethodPut, path.Join(ds.path, p), b, &res)
+	err = ds.client.do(ctx, http.MethodPatch, path.Join(ds.path, p), b, &res)
 	if err != nil {
 		return err
 	}
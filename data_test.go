@@ -2,10 +2,13 @@ package gopa_test
 
 import (
 	"context"
+	"encoding/json"
 	"path"
+	"strings"
 	"testing"
 
 	"github.com/cycloidio/gopa"
+	"github.com/open-policy-agent/opa/server/types"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -84,41 +87,162 @@ allow_request { flag == true }
 		require.NoError(t, err)
 	})
 
+	t.Run("GetWithInput_Options", func(t *testing.T) {
+		c, err := gopa.NewClient()
+		require.NoError(t, err)
+
+		ctx := context.Background()
+		policy := []byte(`
+package opa.examples
+
+import input.example.flag
+
+allow_request { flag == true }
+`)
+		policyID := "example-data-options"
+
+		_, err = c.PolicyCreateOrUpdate(ctx, policyID, policy)
+		require.NoError(t, err)
+
+		input := map[string]interface{}{
+			"example": map[string]interface{}{
+				"flag": true,
+			},
+		}
+
+		res, err := c.DataGetWithInput(ctx, "/opa/examples/allow_request", input,
+			gopa.WithExplain(types.ExplainFullV1),
+			gopa.WithMetrics(),
+			gopa.WithInstrument(),
+			gopa.WithProvenance(),
+			gopa.WithStrictBuiltinErrors(),
+		)
+		require.NoError(t, err)
+		r := *res.Result
+		assert.Equal(t, true, r.(bool))
+		assert.NotEmpty(t, res.Explanation)
+		assert.NotEmpty(t, res.Metrics)
+		assert.Contains(t, res.Metrics, "timer_rego_query_eval_ns")
+		assert.Contains(t, res.Metrics, "timer_query_compile_stage_check_types_ns", "Instrumentation metrics")
+		assert.NotNil(t, res.Provenance)
+
+		res, err = c.DataGet(ctx, "/opa/examples/allow_request", gopa.WithMetrics())
+		require.NoError(t, err)
+		assert.NotEmpty(t, res.Metrics)
+		assert.Empty(t, res.Explanation)
+		assert.Nil(t, res.Provenance)
+
+		_, err = c.PolicyDelete(ctx, policyID)
+		require.NoError(t, err)
+	})
+
 	t.Run("Update", func(t *testing.T) {
 		c, err := gopa.NewClient()
 		require.NoError(t, err)
-		newBody := map[string]interface{}{
-			"example2": map[string]interface{}{
-				"key2": "value",
-				"map": map[string]interface{}{
-					"key3": "value3",
-				},
+		newValue := map[string]interface{}{
+			"key2": "value",
+			"map": map[string]interface{}{
+				"key3": "value3",
 			},
 		}
 
 		ctx := context.Background()
 
-		err = c.DataUpdate(ctx, dataRootPath, newBody)
+		patch := gopa.Patch{}.
+			Add(gopa.PatchPath("example2"), newValue).
+			Replace(gopa.PatchPath("example", "key"), "new-value")
+		err = c.DataUpdate(ctx, dataRootPath, patch)
 		require.NoError(t, err)
 
 		res, err := c.DataGet(ctx, dataRootPath)
 		require.NoError(t, err)
 		r := *res.Result
-		assert.Equal(t, newBody, r.(map[string]interface{}), "New updated body")
+		assert.Equal(t, map[string]interface{}{
+			"example": map[string]interface{}{
+				"key": "new-value",
+			},
+			"example2": newValue,
+		}, r.(map[string]interface{}), "New updated body")
+
+		patch = gopa.Patch{}.
+			Remove(gopa.PatchPath("key3")).
+			Add(gopa.PatchPath("key/4"), "value4")
+		err = c.DataUpdate(ctx, path.Join(dataRootPath, "example2", "map"), patch)
+		require.NoError(t, err)
+
+		res, err = c.DataGet(ctx, path.Join(dataRootPath, "example2"))
+		require.NoError(t, err)
+		r = *res.Result
+		assert.Equal(t, map[string]interface{}{
+			"key2": "value",
+			"map": map[string]interface{}{
+				"key/4": "value4",
+			},
+		}, r.(map[string]interface{}), "New patch updated body")
+
+		err = c.DataUpdate(ctx, dataRootPath, gopa.Patch{}.Remove(gopa.PatchPath("potato")))
+		assert.Error(t, err)
+	})
+
+	t.Run("CreateOrOverride_Types", func(t *testing.T) {
+		c, err := gopa.NewClient()
+		require.NoError(t, err)
 
-		newMapBody := map[string]interface{}{
-			"key4": "value4",
+		ctx := context.Background()
+
+		type server struct {
+			Name  string `json:"name"`
+			Ports []int  `json:"ports"`
 		}
-		err = c.DataUpdate(ctx, path.Join(dataRootPath, "map"), newMapBody)
+
+		for name, tc := range map[string]struct {
+			data     interface{}
+			expected interface{}
+		}{
+			"Scalar":     {data: 42, expected: float64(42)},
+			"Array":      {data: []string{"a", "b"}, expected: []interface{}{"a", "b"}},
+			"Struct":     {data: server{Name: "web", Ports: []int{80}}, expected: map[string]interface{}{"name": "web", "ports": []interface{}{float64(80)}}},
+			"RawMessage": {data: json.RawMessage(`{"raw": true}`), expected: map[string]interface{}{"raw": true}},
+			"Reader":     {data: strings.NewReader(`["streamed"]`), expected: []interface{}{"streamed"}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				p := path.Join(dataRootPath, "types", name)
+				err := c.DataCreateOrOverride(ctx, p, tc.data)
+				require.NoError(t, err)
+
+				res, err := c.DataGet(ctx, p)
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, *res.Result)
+			})
+		}
+	})
+
+	t.Run("GetWithInput_Types", func(t *testing.T) {
+		c, err := gopa.NewClient()
 		require.NoError(t, err)
 
-		endBody := newBody
-		endBody["map"] = newMapBody
+		ctx := context.Background()
 
-		res, err = c.DataGet(ctx, dataRootPath)
+		policyID := "example-input-types"
+		_, err = c.PolicyCreateOrUpdate(ctx, policyID, []byte("package opa.types\n\nfirst = input[0]\n"))
 		require.NoError(t, err)
-		r = *res.Result
-		assert.Equal(t, endBody, r.(map[string]interface{}), "New patch updated body")
+		defer c.PolicyDelete(ctx, policyID)
+
+		for name, input := range map[string]interface{}{
+			"Array":      []string{"a", "b"},
+			"RawMessage": json.RawMessage(`["a"]`),
+			"Reader":     strings.NewReader(`["a", "c"]`),
+		} {
+			t.Run(name, func(t *testing.T) {
+				res, err := c.DataGetWithInput(ctx, "opa/types/first", input)
+				require.NoError(t, err)
+				assert.Equal(t, "a", *res.Result)
+			})
+		}
+
+		b, err := c.QuerySimple(ctx, "/opa/types/first", strings.NewReader(`["a"]`))
+		require.NoError(t, err)
+		assert.NotEmpty(t, b)
 	})
 
 	t.Run("Delete", func(t *testing.T) {
@@ -0,0 +1,75 @@
+package gopa
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// Decision is the result of a decision decoded into T
+type Decision[T any] struct {
+	// Result is the decoded document, it's the
+	// zero value of T when it's not Defined
+	Result T
+	// Defined is false when the document is undefined,
+	// like a rule whose conditions are not met
+	Defined bool
+
+	DecisionID  string
+	Provenance  *types.ProvenanceV1
+	Explanation types.TraceV1
+	Metrics     types.MetricsV1
+}
+
+// Decide evaluates the document on the path with the input and decodes
+// the result into T. The input accepts the same values as the one of
+// DataGetWithInput, like a struct, and nil means no input. The opts
+// can be used to ask for explanations, metrics or provenance. The result
+// is decoded from the exact JSON, so the numbers keep all the digits
+func Decide[T any](ctx context.Context, s Service, path string, input interface{}, opts ...RequestOptionFunc) (*Decision[T], error) {
+	opts = append(opts[:len(opts):len(opts)], WithDecodeMode(DecodeRawMessage))
+
+	var (
+		res *types.DataResponseV1
+		err error
+	)
+	if input == nil {
+		res, err = s.DataGet(ctx, path, opts...)
+	} else {
+		res, err = s.DataGetWithInput(ctx, path, input, opts...)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return newDecision[T](res)
+}
+
+// newDecision decodes the result of the res into the Decision
+func newDecision[T any](res *types.DataResponseV1) (*Decision[T], error) {
+	d := &Decision[T]{
+		DecisionID:  res.DecisionID,
+		Provenance:  res.Provenance,
+		Explanation: res.Explanation,
+		Metrics:     res.Metrics,
+	}
+
+	if res.Result == nil {
+		return d, nil
+	}
+	d.Defined = true
+
+	b, err := json.Marshal(*res.Result)
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(b, &d.Result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the result into %T: %w", d.Result, err)
+	}
+
+	return d, nil
+}
@@ -0,0 +1,74 @@
+package gopa_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecide(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := gopa.NewClient()
+	require.NoError(t, err)
+
+	policyID := "example-decide"
+	_, err = c.PolicyCreateOrUpdate(ctx, policyID, []byte(`
+package opa.decide
+
+allow { input.user.name == "alice" }
+
+limits = {"max": 10, "tags": ["a", "b"]}
+
+count = 0
+`))
+	require.NoError(t, err)
+	defer c.PolicyDelete(ctx, policyID)
+
+	type user struct {
+		Name string `json:"name"`
+	}
+	type input struct {
+		User user `json:"user"`
+	}
+	type limits struct {
+		Max  int      `json:"max"`
+		Tags []string `json:"tags"`
+	}
+
+	t.Run("Struct", func(t *testing.T) {
+		d, err := gopa.Decide[bool](ctx, c, "opa/decide/allow", input{User: user{Name: "alice"}})
+		require.NoError(t, err)
+		assert.True(t, d.Defined)
+		assert.True(t, d.Result)
+
+		l, err := gopa.Decide[limits](ctx, c, "opa/decide/limits", nil, gopa.WithMetrics())
+		require.NoError(t, err)
+		assert.True(t, l.Defined)
+		assert.Equal(t, limits{Max: 10, Tags: []string{"a", "b"}}, l.Result)
+		assert.NotEmpty(t, l.Metrics)
+	})
+
+	t.Run("Undefined", func(t *testing.T) {
+		d, err := gopa.Decide[bool](ctx, c, "opa/decide/allow", map[string]interface{}{"user": map[string]interface{}{"name": "bob"}})
+		require.NoError(t, err)
+		assert.False(t, d.Defined)
+		assert.False(t, d.Result)
+
+		n, err := gopa.Decide[int](ctx, c, "opa/decide/count", nil)
+		require.NoError(t, err)
+		assert.True(t, n.Defined, "The zero value is not undefined")
+		assert.Equal(t, 0, n.Result)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := gopa.Decide[int](ctx, c, "opa/decide/limits", nil)
+		assert.Error(t, err)
+
+		_, err = gopa.Decide[bool](ctx, c, "opa/decide/allow", func() {})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,114 @@
+package gopa
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// DecodeMode is how the JSON values of the
+// results of the responses are decoded
+type DecodeMode int
+
+// List of the possible DecodeMode
+const (
+	// DecodeDefault uses the DecodeMode of the Client,
+	// which is DecodeFloat64 if none is set
+	DecodeDefault DecodeMode = iota
+	// DecodeFloat64 decodes the numbers as float64, like
+	// encoding/json does, so the big integers lose precision
+	DecodeFloat64
+	// DecodeUseNumber decodes the numbers as json.Number
+	// so they keep all the digits
+	DecodeUseNumber
+	// DecodeRawMessage leaves the results as json.RawMessage,
+	// with the numbers as they were, so they can be decoded later
+	DecodeRawMessage
+)
+
+// SetDecodeMode sets how the results of the Data and Query responses
+// are decoded, by default DecodeFloat64. The Compile results are always
+// exact as they are decoded into AST
+func SetDecodeMode(m DecodeMode) ClientOptionFunc {
+	return func(c *Client) error {
+		c.decode = m
+		return nil
+	}
+}
+
+// WithDecodeMode sets how the result of the request is decoded,
+// overriding the DecodeMode of the Client
+func WithDecodeMode(m DecodeMode) RequestOptionFunc {
+	return func(o *RequestOptions) {
+		o.Decode = m
+	}
+}
+
+// or returns m or, if it's DecodeDefault, the d
+func (m DecodeMode) or(d DecodeMode) DecodeMode {
+	if m == DecodeDefault {
+		return d
+	}
+	return m
+}
+
+// decode decodes the JSON of r into the response
+func (m DecodeMode) decode(r io.Reader, response interface{}) error {
+	dec := json.NewDecoder(r)
+	if m == DecodeUseNumber || m == DecodeRawMessage {
+		dec.UseNumber()
+	}
+
+	err := dec.Decode(response)
+	if err != nil {
+		return err
+	}
+
+	if m != DecodeRawMessage {
+		return nil
+	}
+
+	// The json.Number keep the digits when
+	// they are encoded to the json.RawMessage
+	switch res := response.(type) {
+	case *interface{}:
+		*res, err = rawMessage(*res)
+	case *dataResponse:
+		if res.Result != nil {
+			*res.Result, err = rawMessage(*res.Result)
+		}
+	case *types.QueryResponseV1:
+		for _, bindings := range res.Result {
+			for k, v := range bindings {
+				if bindings[k], err = rawMessage(v); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return err
+}
+
+// roundTrip converts the v to the types it would
+// have if it was decoded from a JSON response
+func (m DecodeMode) roundTrip(v *interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return m.decode(bytes.NewReader(b), v)
+}
+
+// rawMessage encodes the v to a json.RawMessage
+func rawMessage(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.RawMessage(b), nil
+}
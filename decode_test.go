@@ -0,0 +1,102 @@
+package gopa_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeMode(t *testing.T) {
+	ctx := context.Background()
+
+	// 2^53+1 can not be represented as float64
+	data := json.RawMessage(`{"id":9007199254740993,"size":1.5}`)
+
+	c, err := gopa.NewClient()
+	require.NoError(t, err)
+
+	e, err := gopa.NewEmbeddedClient()
+	require.NoError(t, err)
+
+	for name, s := range map[string]gopa.Service{"Client": c, "Embedded": e} {
+		t.Run(name, func(t *testing.T) {
+			err := s.DataCreateOrOverride(ctx, "decode", data)
+			require.NoError(t, err)
+			defer s.DataDelete(ctx, "decode")
+
+			t.Run("Float64", func(t *testing.T) {
+				res, err := s.DataGet(ctx, "decode/id")
+				require.NoError(t, err)
+				assert.Equal(t, float64(9007199254740992), *res.Result)
+			})
+
+			t.Run("UseNumber", func(t *testing.T) {
+				res, err := s.DataGet(ctx, "decode", gopa.WithDecodeMode(gopa.DecodeUseNumber))
+				require.NoError(t, err)
+				assert.Equal(t, map[string]interface{}{
+					"id":   json.Number("9007199254740993"),
+					"size": json.Number("1.5"),
+				}, *res.Result)
+
+				// The json.Number are written back as they are
+				err = s.DataCreateOrOverride(ctx, "decode/copy", *res.Result)
+				require.NoError(t, err)
+
+				res, err = s.DataGet(ctx, "decode/copy/id", gopa.WithDecodeMode(gopa.DecodeUseNumber))
+				require.NoError(t, err)
+				assert.Equal(t, json.Number("9007199254740993"), *res.Result)
+			})
+
+			t.Run("RawMessage", func(t *testing.T) {
+				res, err := s.DataGetWithInput(ctx, "decode/id", map[string]interface{}{}, gopa.WithDecodeMode(gopa.DecodeRawMessage))
+				require.NoError(t, err)
+				assert.Equal(t, json.RawMessage(`9007199254740993`), *res.Result)
+			})
+
+			t.Run("Decide", func(t *testing.T) {
+				d, err := gopa.Decide[uint64](ctx, s, "decode/id", nil)
+				require.NoError(t, err)
+				assert.Equal(t, uint64(9007199254740993), d.Result)
+			})
+		})
+	}
+
+	t.Run("SetDecodeMode", func(t *testing.T) {
+		c, err := gopa.NewClient(gopa.SetDecodeMode(gopa.DecodeUseNumber))
+		require.NoError(t, err)
+
+		err = c.DataCreateOrOverride(ctx, "decode", data)
+		require.NoError(t, err)
+		defer c.DataDelete(ctx, "decode")
+
+		res, err := c.DataGet(ctx, "decode/id")
+		require.NoError(t, err)
+		assert.Equal(t, json.Number("9007199254740993"), *res.Result)
+
+		res, err = c.DataGet(ctx, "decode/id", gopa.WithDecodeMode(gopa.DecodeFloat64))
+		require.NoError(t, err)
+		assert.Equal(t, float64(9007199254740992), *res.Result)
+
+		q, err := c.QueryAdHoc(ctx, "", gopa.QueryAdHocOptions{Query: "x := data.decode.id"})
+		require.NoError(t, err)
+		require.Len(t, q.Result, 1)
+		assert.Equal(t, json.Number("9007199254740993"), q.Result[0]["x"])
+	})
+
+	t.Run("SetEmbeddedDecodeMode", func(t *testing.T) {
+		e, err := gopa.NewEmbeddedClient(gopa.SetEmbeddedDecodeMode(gopa.DecodeRawMessage))
+		require.NoError(t, err)
+
+		err = e.DataCreateOrOverride(ctx, "decode", data)
+		require.NoError(t, err)
+
+		q, err := e.QueryAdHoc(ctx, "", gopa.QueryAdHocOptions{Query: "x := data.decode.id"})
+		require.NoError(t, err)
+		require.Len(t, q.Result, 1)
+		assert.Equal(t, json.RawMessage(`9007199254740993`), q.Result[0]["x"])
+	})
+}
@@ -0,0 +1,786 @@
+package gopa
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/config"
+	"github.com/open-policy-agent/opa/metrics"
+	"github.com/open-policy-agent/opa/rego"
+	"github.com/open-policy-agent/opa/server/types"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/topdown/lineage"
+	"github.com/open-policy-agent/opa/util"
+	"github.com/open-policy-agent/opa/version"
+)
+
+// EmbeddedClient is a Service that evaluates the policies
+// in-process with the OPA Go packages instead of using an
+// OPA server. The errors are the same APIError that
+// the server would return
+type EmbeddedClient struct {
+	store storage.Store
+
+	// mu guards the compiler, which is replaced every
+	// time the policies change, and serializes the
+	// policy writes so the compiler is never stale
+	mu       sync.RWMutex
+	compiler *ast.Compiler
+
+	decode DecodeMode
+}
+
+// DefaultDecision is the path evaluated by the
+// QuerySimple of the EmbeddedClient
+const DefaultDecision = "/system/main"
+
+// EmbeddedOptionFunc is a type used to configure the
+// EmbeddedClient on initialization time
+type EmbeddedOptionFunc func(*EmbeddedClient) error
+
+// SetStore sets the storage used for the policies and data,
+// by default an empty in-memory storage is used
+func SetStore(s storage.Store) EmbeddedOptionFunc {
+	return func(e *EmbeddedClient) error {
+		e.store = s
+		return nil
+	}
+}
+
+// SetEmbeddedDecodeMode sets how the results of the DataGet and
+// QueryAdHoc are converted, like SetDecodeMode does on the Client
+func SetEmbeddedDecodeMode(m DecodeMode) EmbeddedOptionFunc {
+	return func(e *EmbeddedClient) error {
+		e.decode = m
+		return nil
+	}
+}
+
+// NewEmbeddedClient initializes a new EmbeddedClient that
+// can be configured with the opts
+func NewEmbeddedClient(opts ...EmbeddedOptionFunc) (*EmbeddedClient, error) {
+	e := &EmbeddedClient{
+		store: inmem.New(),
+	}
+
+	for _, o := range opts {
+		if err := o(e); err != nil {
+			return nil, err
+		}
+	}
+
+	// The store could already have policies
+	ctx := context.Background()
+	err := storage.Txn(ctx, e.store, storage.TransactionParams{}, func(txn storage.Transaction) error {
+		modules, err := e.loadModules(ctx, txn)
+		if err != nil {
+			return err
+		}
+
+		e.compiler, err = compileModules(modules, types.CodeInvalidParameter)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// PolicyCreateOrUpdate creates or updates the policy with the give id and the content policy
+func (e *EmbeddedClient) PolicyCreateOrUpdate(ctx context.Context, id string, policy []byte) (*types.PolicyPutResponseV1, error) {
+	mod, err := parseModule(id, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var c *ast.Compiler
+	err = storage.Txn(ctx, e.store, storage.WriteParams, func(txn storage.Transaction) error {
+		modules, err := e.loadModules(ctx, txn)
+		if err != nil {
+			return err
+		}
+
+		modules[id] = mod
+		c, err = compileModules(modules, types.CodeInvalidParameter)
+		if err != nil {
+			var apiErr *APIError
+			if errors.As(err, &apiErr) {
+				apiErr.setSource(id, policy)
+			}
+			return err
+		}
+
+		return e.store.UpsertPolicy(ctx, txn, id, policy)
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	e.compiler = c
+
+	return &types.PolicyPutResponseV1{}, nil
+}
+
+// PolicyList returns all the policies
+func (e *EmbeddedClient) PolicyList(ctx context.Context) (*types.PolicyListResponseV1, error) {
+	res := &types.PolicyListResponseV1{
+		Result: []types.PolicyV1{},
+	}
+
+	err := storage.Txn(ctx, e.store, storage.TransactionParams{}, func(txn storage.Transaction) error {
+		ids, err := e.store.ListPolicies(ctx, txn)
+		if err != nil {
+			return err
+		}
+
+		sort.Strings(ids)
+		for _, id := range ids {
+			p, err := e.getPolicy(ctx, txn, id)
+			if err != nil {
+				return err
+			}
+			res.Result = append(res.Result, p)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return res, nil
+}
+
+// PolicyGet returns the policy with the given id
+func (e *EmbeddedClient) PolicyGet(ctx context.Context, id string) (*types.PolicyGetResponseV1, error) {
+	var res types.PolicyGetResponseV1
+
+	err := storage.Txn(ctx, e.store, storage.TransactionParams{}, func(txn storage.Transaction) error {
+		p, err := e.getPolicy(ctx, txn, id)
+		if err != nil {
+			return err
+		}
+
+		res.Result = p
+		return nil
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	return &res, nil
+}
+
+// PolicyDelete deletes the policy with the given id
+func (e *EmbeddedClient) PolicyDelete(ctx context.Context, id string) (*types.PolicyDeleteResponseV1, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var c *ast.Compiler
+	err := storage.Txn(ctx, e.store, storage.WriteParams, func(txn storage.Transaction) error {
+		if _, err := e.store.GetPolicy(ctx, txn, id); err != nil {
+			return err
+		}
+
+		modules, err := e.loadModules(ctx, txn)
+		if err != nil {
+			return err
+		}
+
+		// The other policies could depend on this one
+		delete(modules, id)
+		c, err = compileModules(modules, types.CodeInvalidOperation)
+		if err != nil {
+			return err
+		}
+
+		return e.store.DeletePolicy(ctx, txn, id)
+	})
+	if err != nil {
+		return nil, storageError(err)
+	}
+
+	e.compiler = c
+
+	return &types.PolicyDeleteResponseV1{}, nil
+}
+
+// DataCreateOrOverride creates or replaces the given data on the path p
+func (e *EmbeddedClient) DataCreateOrOverride(ctx context.Context, p string, data interface{}) error {
+	path, err := dataPath(p)
+	if err != nil {
+		return err
+	}
+
+	value, err := jsonValue(data)
+	if err != nil {
+		return err
+	}
+
+	err = storage.Txn(ctx, e.store, storage.WriteParams, func(txn storage.Transaction) error {
+		if _, err := e.store.Read(ctx, txn, path); err != nil {
+			if !storage.IsNotFound(err) {
+				return err
+			}
+			if len(path) > 0 {
+				if err := storage.MakeDir(ctx, e.store, txn, path[:len(path)-1]); err != nil {
+					return err
+				}
+			}
+		}
+
+		return e.store.Write(ctx, txn, storage.AddOp, path, value)
+	})
+
+	return storageError(err)
+}
+
+// DataGet get's the data on the given path p. The StrictBuiltinErrors
+// option is not supported by this version of OPA
+func (e *EmbeddedClient) DataGet(ctx context.Context, p string, opts ...RequestOptionFunc) (*types.DataResponseV1, error) {
+	return e.evalData(ctx, p, nil, opts)
+}
+
+// DataGetWithInput get's the data on the given path p with the input i. The
+// StrictBuiltinErrors option is not supported by this version of OPA
+func (e *EmbeddedClient) DataGetWithInput(ctx context.Context, p string, input interface{}, opts ...RequestOptionFunc) (*types.DataResponseV1, error) {
+	i, err := jsonValue(input)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.evalData(ctx, p, rego.Input(i), opts)
+}
+
+// DataUpdate applies the patch to the data on the given path p
+func (e *EmbeddedClient) DataUpdate(ctx context.Context, p string, patch Patch) error {
+	root := "/" + strings.Trim(p, "/")
+
+	type patchImpl struct {
+		path  storage.Path
+		op    storage.PatchOp
+		value interface{}
+	}
+
+	impls := make([]patchImpl, 0, len(patch))
+	for _, pt := range patch {
+		impl := patchImpl{value: pt.Value}
+
+		switch pt.Op {
+		case PatchOpAdd:
+			impl.op = storage.AddOp
+		case PatchOpRemove:
+			impl.op = storage.RemoveOp
+		case PatchOpReplace:
+			impl.op = storage.ReplaceOp
+		default:
+			return &APIError{Code: types.CodeInvalidParameter, Message: types.BadPatchOperationErr(pt.Op).Error()}
+		}
+
+		if err := util.RoundTrip(&impl.value); err != nil {
+			return &APIError{Code: types.CodeInvalidParameter, Message: err.Error()}
+		}
+
+		path, ok := storage.ParsePathEscaped(strings.TrimSuffix(root, "/") + "/" + strings.Trim(pt.Path, "/"))
+		if !ok {
+			return &APIError{Code: types.CodeInvalidParameter, Message: types.BadPatchPathErr(pt.Path).Error()}
+		}
+		for i := range path {
+			path[i] = strings.Replace(path[i], "~1", "/", -1)
+			path[i] = strings.Replace(path[i], "~0", "~", -1)
+		}
+		impl.path = path
+
+		impls = append(impls, impl)
+	}
+
+	err := storage.Txn(ctx, e.store, storage.WriteParams, func(txn storage.Transaction) error {
+		for _, impl := range impls {
+			if err := e.store.Write(ctx, txn, impl.op, impl.path, impl.value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return storageError(err)
+}
+
+// DataDelete deletes the data on the given path p
+func (e *EmbeddedClient) DataDelete(ctx context.Context, p string) error {
+	path, err := dataPath(p)
+	if err != nil {
+		return err
+	}
+
+	err = storage.Txn(ctx, e.store, storage.WriteParams, func(txn storage.Transaction) error {
+		if _, err := e.store.Read(ctx, txn, path); err != nil {
+			return err
+		}
+
+		return e.store.Write(ctx, txn, storage.RemoveOp, path, nil)
+	})
+
+	return storageError(err)
+}
+
+// QuerySimple evaluates the document on the path p, or the
+// DefaultDecision if empty, with the given input and
+// returns the JSON result
+func (e *EmbeddedClient) QuerySimple(ctx context.Context, p string, input interface{}) ([]byte, error) {
+	if strings.Trim(p, "/") == "" {
+		p = DefaultDecision
+	}
+
+	i, err := jsonValue(input)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := e.evalData(ctx, p, rego.Input(i), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.Result == nil {
+		return nil, &APIError{Code: types.CodeUndefinedDocument, Message: fmt.Sprintf("%s decision was undefined", p)}
+	}
+
+	return json.Marshal(*res.Result)
+}
+
+// QueryAdHoc makes a AdHoc query with the give opt, the path is ignored
+func (e *EmbeddedClient) QueryAdHoc(ctx context.Context, p string, opt QueryAdHocOptions) (*types.QueryResponseV1, error) {
+	options := []func(*rego.Rego){
+		rego.Query(opt.Query),
+	}
+	if opt.Input != nil {
+		i, err := jsonValue(opt.Input)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, rego.Input(i))
+	}
+
+	rs, err := e.eval(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &types.QueryResponseV1{
+		Result: types.AdhocQueryResultSetV1{},
+	}
+	for _, r := range rs {
+		b := make(map[string]interface{}, len(r.Bindings))
+		for k, v := range r.Bindings {
+			// The expressions without bindings are not returned by OPA
+			if strings.HasPrefix(k, ast.WildcardPrefix) {
+				continue
+			}
+			if err := e.decode.roundTrip(&v); err != nil {
+				return nil, err
+			}
+			b[k] = v
+		}
+		res.Result = append(res.Result, b)
+	}
+
+	return res, nil
+}
+
+// CompilePartial partially evaluates the query with the given opt
+func (e *EmbeddedClient) CompilePartial(ctx context.Context, opt CompileOptions) (*CompileResponse, error) {
+	options := []func(*rego.Rego){
+		rego.Query(opt.Query),
+		rego.Compiler(e.getCompiler()),
+		rego.Store(e.store),
+	}
+	if opt.Input != nil {
+		i, err := jsonValue(opt.Input)
+		if err != nil {
+			return nil, err
+		}
+		options = append(options, rego.Input(i))
+	}
+	if opt.Unknowns != nil {
+		options = append(options, rego.Unknowns(opt.Unknowns))
+	}
+
+	pq, err := rego.New(options...).Partial(ctx)
+	if err != nil {
+		return nil, evalError(err)
+	}
+
+	return &CompileResponse{
+		Result: &types.PartialEvaluationResultV1{
+			Queries: pq.Queries,
+			Support: pq.Support,
+		},
+	}, nil
+}
+
+// Health always returns HealthStatusHealthy as there
+// is nothing to reach
+func (e *EmbeddedClient) Health(ctx context.Context, opt HealthOptions) (*HealthResponse, error) {
+	return &HealthResponse{
+		Status:     HealthStatusHealthy,
+		StatusCode: 200,
+	}, nil
+}
+
+// ConfigGet returns an empty configuration as there
+// is no server to configure
+func (e *EmbeddedClient) ConfigGet(ctx context.Context) (*ConfigResponse, error) {
+	return &ConfigResponse{
+		Result: &config.Config{},
+	}, nil
+}
+
+// StatusGet returns an empty status as there are
+// no bundles nor plugins
+func (e *EmbeddedClient) StatusGet(ctx context.Context) (*StatusResponse, error) {
+	return &StatusResponse{
+		Result: &Status{},
+	}, nil
+}
+
+// evalData evaluates the document on the path p with the
+// input, if not nil, and the opts, like the server does
+func (e *EmbeddedClient) evalData(ctx context.Context, p string, input func(*rego.Rego), opts []RequestOptionFunc) (*types.DataResponseV1, error) {
+	ref, err := dataRef(p)
+	if err != nil {
+		return nil, err
+	}
+
+	ro := newRequestOptions(opts)
+	m := metrics.New()
+
+	options := []func(*rego.Rego){
+		rego.Query(ref.String()),
+		rego.Metrics(m),
+		rego.Instrument(ro.Instrument),
+	}
+	if input != nil {
+		options = append(options, input)
+	}
+
+	var buf *topdown.BufferTracer
+	if ro.Explain != "" && ro.Explain != types.ExplainOffV1 {
+		buf = topdown.NewBufferTracer()
+		options = append(options, rego.QueryTracer(buf))
+	}
+
+	rs, err := e.eval(ctx, options)
+	if err != nil {
+		return nil, err
+	}
+
+	var res types.DataResponseV1
+
+	if ro.Metrics || ro.Instrument {
+		res.Metrics = m.All()
+	}
+
+	if ro.Provenance {
+		res.Provenance = &types.ProvenanceV1{
+			Version:   version.Version,
+			Vcs:       version.Vcs,
+			Timestamp: version.Timestamp,
+			Hostname:  version.Hostname,
+		}
+	}
+
+	if buf != nil {
+		events := *buf
+		switch ro.Explain {
+		case types.ExplainNotesV1:
+			events = lineage.Notes(events)
+		case types.ExplainFailsV1:
+			events = lineage.Fails(events)
+		}
+
+		res.Explanation, err = types.NewTraceV1(events, ro.Pretty)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(rs) == 0 {
+		if ro.StrictUndefined {
+			return nil, &UndefinedError{Path: p, Warning: e.missingPath(ctx, p, ref)}
+		}
+		return &res, nil
+	}
+
+	// The value is converted as if it was
+	// decoded from the API response
+	v := rs[0].Expressions[0].Value
+	if err := ro.Decode.or(e.decode).roundTrip(&v); err != nil {
+		return nil, err
+	}
+	res.Result = &v
+
+	return &res, nil
+}
+
+// missingPath returns a Warning if no policy or data exists
+// on the path p, or nil if it exists but it's undefined
+func (e *EmbeddedClient) missingPath(ctx context.Context, p string, ref ast.Ref) *Warning {
+	if len(e.getCompiler().GetRules(ref)) > 0 {
+		return nil
+	}
+
+	path, err := dataPath(p)
+	if err != nil {
+		return nil
+	}
+	if _, err := storage.ReadOne(ctx, e.store, path); !storage.IsNotFound(err) {
+		return nil
+	}
+
+	return &Warning{Code: types.CodeUndefinedDocument, Message: "requested path not found"}
+}
+
+// eval evaluates the rego with the options
+// using the current compiler and store
+func (e *EmbeddedClient) eval(ctx context.Context, options []func(*rego.Rego)) (rego.ResultSet, error) {
+	options = append(options,
+		rego.Compiler(e.getCompiler()),
+		rego.Store(e.store),
+	)
+
+	rs, err := rego.New(options...).Eval(ctx)
+	if err != nil {
+		return nil, evalError(err)
+	}
+
+	return rs, nil
+}
+
+// getCompiler returns the current compiler
+func (e *EmbeddedClient) getCompiler() *ast.Compiler {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return e.compiler
+}
+
+// loadModules parses all the policies of the store
+func (e *EmbeddedClient) loadModules(ctx context.Context, txn storage.Transaction) (map[string]*ast.Module, error) {
+	ids, err := e.store.ListPolicies(ctx, txn)
+	if err != nil {
+		return nil, err
+	}
+
+	modules := make(map[string]*ast.Module, len(ids))
+	for _, id := range ids {
+		b, err := e.store.GetPolicy(ctx, txn, id)
+		if err != nil {
+			return nil, err
+		}
+
+		modules[id], err = ast.ParseModule(id, string(b))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return modules, nil
+}
+
+// getPolicy returns the policy with the id from the store
+func (e *EmbeddedClient) getPolicy(ctx context.Context, txn storage.Transaction, id string) (types.PolicyV1, error) {
+	b, err := e.store.GetPolicy(ctx, txn, id)
+	if err != nil {
+		return types.PolicyV1{}, err
+	}
+
+	mod, err := ast.ParseModule(id, string(b))
+	if err != nil {
+		return types.PolicyV1{}, err
+	}
+
+	return types.PolicyV1{
+		ID:  id,
+		Raw: string(b),
+		AST: mod,
+	}, nil
+}
+
+// parseModule parses the policy with the id and returns
+// the APIError the server would return if it fails
+func parseModule(id string, policy []byte) (*ast.Module, error) {
+	mod, err := ast.ParseModule(id, string(policy))
+	if err != nil {
+		var astErrs ast.Errors
+		if errors.As(err, &astErrs) {
+			apiErr := newASTError(types.CodeInvalidParameter, astErrs)
+			apiErr.setSource(id, policy)
+			return nil, apiErr
+		}
+		return nil, &APIError{Code: types.CodeInvalidParameter, Message: err.Error()}
+	}
+
+	if mod == nil {
+		return nil, &APIError{Code: types.CodeInvalidParameter, Message: "empty module"}
+	}
+
+	return mod, nil
+}
+
+// compileModules compiles the modules and returns the
+// compiler or an APIError with the code and the errors
+func compileModules(modules map[string]*ast.Module, code string) (*ast.Compiler, error) {
+	c := ast.NewCompiler()
+	if c.Compile(modules); c.Failed() {
+		return nil, newASTError(code, c.Errors)
+	}
+
+	return c, nil
+}
+
+// newASTError converts the errs to an APIError with the code
+func newASTError(code string, errs ast.Errors) *APIError {
+	apiErr := &APIError{
+		Code:    code,
+		Message: types.MsgCompileModuleError,
+	}
+
+	for _, err := range errs {
+		ae := APIError{
+			Code:    err.Code,
+			Message: err.Message,
+		}
+		if err.Location != nil {
+			ae.Location = Location{
+				File: err.Location.File,
+				Row:  err.Location.Row,
+				Col:  err.Location.Col,
+			}
+		}
+		if err.Details != nil {
+			// The details are decoded as the
+			// client would do with the response
+			if b, err := json.Marshal(err.Details); err == nil {
+				json.Unmarshal(b, &ae.Details)
+			}
+		}
+		apiErr.Errors = append(apiErr.Errors, ae)
+	}
+
+	return apiErr
+}
+
+// storageError converts the storage errors
+// to the APIError the server would return
+func storageError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return err
+	}
+
+	switch {
+	case types.IsBadRequest(err), storage.IsInvalidPatch(err):
+		return &APIError{Code: types.CodeInvalidParameter, Message: err.Error()}
+	case storage.IsWriteConflictError(err):
+		return &APIError{Code: types.CodeResourceConflict, Message: err.Error()}
+	case storage.IsNotFound(err):
+		return &APIError{Code: types.CodeResourceNotFound, Message: err.Error()}
+	}
+
+	return &APIError{Code: types.CodeInternal, Message: err.Error()}
+}
+
+// evalError converts the evaluation errors
+// to the APIError the server would return
+func evalError(err error) error {
+	var astErrs ast.Errors
+	if errors.As(err, &astErrs) {
+		return newASTError(types.CodeInvalidParameter, astErrs)
+	}
+
+	if topdown.IsError(err) {
+		return &APIError{
+			Code:    types.CodeInternal,
+			Message: types.MsgEvaluationError,
+			Errors: []APIError{
+				{Code: types.CodeEvaluation, Message: err.Error()},
+			},
+		}
+	}
+
+	return storageError(err)
+}
+
+// dataPath parses the p to the storage path
+func dataPath(p string) (storage.Path, error) {
+	path, ok := storage.ParsePathEscaped("/" + strings.Trim(p, "/"))
+	if !ok {
+		return nil, &APIError{Code: types.CodeInvalidParameter, Message: fmt.Sprintf("bad path: %v", p)}
+	}
+
+	return path, nil
+}
+
+// dataRef converts the p to a reference of the data
+// document, the numbers are used as array indexes
+func dataRef(p string) (ast.Ref, error) {
+	ref := ast.Ref{ast.DefaultRootDocument}
+	for _, s := range strings.Split(strings.Trim(p, "/"), "/") {
+		if s == "" {
+			continue
+		}
+
+		s, err := url.PathUnescape(s)
+		if err != nil {
+			return nil, &APIError{Code: types.CodeInvalidParameter, Message: fmt.Sprintf("bad path: %v", p)}
+		}
+
+		if i, err := strconv.Atoi(s); err == nil {
+			ref = append(ref, ast.IntNumberTerm(i))
+		} else {
+			ref = append(ref, ast.StringTerm(s))
+		}
+	}
+
+	return ref, nil
+}
+
+// jsonValue converts the v, which can also be a json.RawMessage or an
+// io.Reader with the JSON, to the value the server would decode
+func jsonValue(v interface{}) (interface{}, error) {
+	var (
+		value interface{}
+		err   error
+	)
+	if r, ok := v.(io.Reader); ok {
+		err = util.NewJSONDecoder(r).Decode(&value)
+	} else {
+		value = v
+		err = util.RoundTrip(&value)
+	}
+	if err != nil {
+		return nil, &APIError{Code: types.CodeInvalidParameter, Message: err.Error()}
+	}
+
+	return value, nil
+}
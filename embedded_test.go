@@ -0,0 +1,264 @@
+package gopa_test
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/open-policy-agent/opa/server/types"
+	"github.com/open-policy-agent/opa/storage/inmem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedClient(t *testing.T) {
+	policyID := "example-embedded"
+	policy := []byte(`
+package opa.examples
+
+import input.example.flag
+
+allow_request { is_true(flag) }
+
+is_true(x) { x == true }
+
+allowed_servers[s] { s := data.servers[_]; s.public }
+`)
+
+	ctx := context.Background()
+
+	c, err := gopa.NewEmbeddedClient()
+	require.NoError(t, err)
+
+	assert.Implements(t, (*gopa.Service)(nil), c)
+
+	t.Run("Policy", func(t *testing.T) {
+		t.Run("CreateOrUpdate", func(t *testing.T) {
+			res, err := c.PolicyCreateOrUpdate(ctx, policyID, policy)
+			require.NoError(t, err)
+			assert.NotNil(t, res)
+		})
+
+		t.Run("CreateOrUpdate_Error", func(t *testing.T) {
+			_, err := c.PolicyCreateOrUpdate(ctx, "example-broken", []byte("package opa.broken\n\ndeny { foo(1) }\n"))
+			require.Error(t, err)
+			assert.True(t, gopa.IsInvalidParameter(err))
+			assert.True(t, gopa.IsCompileError(err))
+			assert.Contains(t, err.Error(), "example-broken:3:8: rego_type_error: undefined function foo\n\tdeny { foo(1) }")
+
+			_, err = c.PolicyCreateOrUpdate(ctx, "example-broken", []byte("potato"))
+			require.Error(t, err)
+			assert.True(t, gopa.IsCompileError(err))
+		})
+
+		t.Run("List", func(t *testing.T) {
+			res, err := c.PolicyList(ctx)
+			require.NoError(t, err)
+			require.Len(t, res.Result, 1)
+			assert.Equal(t, policyID, res.Result[0].ID)
+			assert.Equal(t, string(policy), res.Result[0].Raw)
+		})
+
+		t.Run("Get", func(t *testing.T) {
+			res, err := c.PolicyGet(ctx, policyID)
+			require.NoError(t, err)
+			assert.Equal(t, policyID, res.Result.ID)
+			assert.Equal(t, string(policy), res.Result.Raw)
+
+			_, err = c.PolicyGet(ctx, "invalidID")
+			assert.True(t, gopa.IsNotFound(err))
+		})
+
+		t.Run("Delete_Dependency", func(t *testing.T) {
+			_, err := c.PolicyCreateOrUpdate(ctx, "example-dependency", []byte(`
+package opa.dependency
+
+allow { data.opa.examples.is_true(input.flag) }
+`))
+			require.NoError(t, err)
+
+			_, err = c.PolicyDelete(ctx, policyID)
+			require.Error(t, err)
+			assert.Equal(t, types.CodeInvalidOperation, err.(*gopa.APIError).Code)
+
+			_, err = c.PolicyDelete(ctx, "example-dependency")
+			require.NoError(t, err)
+
+			_, err = c.PolicyDelete(ctx, "invalidID")
+			assert.True(t, gopa.IsNotFound(err))
+		})
+	})
+
+	t.Run("Data", func(t *testing.T) {
+		dataRootPath := "servers"
+		dataBody := map[string]interface{}{
+			"web": map[string]interface{}{
+				"public": true,
+				"port":   80,
+			},
+			"db": map[string]interface{}{
+				"public": false,
+				"port":   5432,
+			},
+		}
+
+		t.Run("CreateOrOverride", func(t *testing.T) {
+			err := c.DataCreateOrOverride(ctx, dataRootPath, dataBody)
+			require.NoError(t, err)
+
+			err = c.DataCreateOrOverride(ctx, "nested/path/doc", map[string]interface{}{"key": "value"})
+			require.NoError(t, err)
+
+			err = c.DataCreateOrOverride(ctx, "nested/path/list", strings.NewReader(`["a", 1]`))
+			require.NoError(t, err)
+		})
+
+		t.Run("Get", func(t *testing.T) {
+			res, err := c.DataGet(ctx, dataRootPath)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{
+				"web": map[string]interface{}{
+					"public": true,
+					"port":   float64(80),
+				},
+				"db": map[string]interface{}{
+					"public": false,
+					"port":   float64(5432),
+				},
+			}, *res.Result)
+
+			res, err = c.DataGet(ctx, "nested/path/doc/key")
+			require.NoError(t, err)
+			assert.Equal(t, "value", *res.Result)
+
+			res, err = c.DataGet(ctx, "nested/path/list")
+			require.NoError(t, err)
+			assert.Equal(t, []interface{}{"a", float64(1)}, *res.Result)
+
+			res, err = c.DataGet(ctx, "/opa/examples/allowed_servers")
+			require.NoError(t, err)
+			assert.Len(t, *res.Result, 1)
+
+			res, err = c.DataGet(ctx, "potato")
+			require.NoError(t, err)
+			assert.Nil(t, res.Result)
+		})
+
+		t.Run("GetWithInput", func(t *testing.T) {
+			input := map[string]interface{}{
+				"example": map[string]interface{}{
+					"flag": true,
+				},
+			}
+
+			res, err := c.DataGetWithInput(ctx, "/opa/examples/allow_request", input,
+				gopa.WithExplain(types.ExplainFullV1),
+				gopa.WithMetrics(),
+				gopa.WithProvenance(),
+			)
+			require.NoError(t, err)
+			assert.Equal(t, true, *res.Result)
+			assert.NotEmpty(t, res.Metrics)
+			assert.NotNil(t, res.Provenance)
+
+			tr, err := gopa.DecodeTrace(res.Explanation)
+			require.NoError(t, err)
+			assert.NotEmpty(t, tr)
+		})
+
+		t.Run("Update", func(t *testing.T) {
+			err := c.DataUpdate(ctx, dataRootPath, gopa.Patch{}.
+				Replace(gopa.PatchPath("web", "port"), 8080).
+				Remove(gopa.PatchPath("db")))
+			require.NoError(t, err)
+
+			res, err := c.DataGet(ctx, dataRootPath)
+			require.NoError(t, err)
+			assert.Equal(t, map[string]interface{}{
+				"web": map[string]interface{}{
+					"public": true,
+					"port":   float64(8080),
+				},
+			}, *res.Result)
+
+			err = c.DataUpdate(ctx, dataRootPath, gopa.Patch{}.Remove(gopa.PatchPath("potato")))
+			assert.True(t, gopa.IsNotFound(err))
+		})
+
+		t.Run("Delete", func(t *testing.T) {
+			err := c.DataDelete(ctx, path.Join(dataRootPath, "web"))
+			require.NoError(t, err)
+
+			res, err := c.DataGet(ctx, path.Join(dataRootPath, "web"))
+			require.NoError(t, err)
+			assert.Nil(t, res.Result)
+
+			err = c.DataDelete(ctx, "potato")
+			assert.True(t, gopa.IsNotFound(err))
+		})
+	})
+
+	t.Run("Query", func(t *testing.T) {
+		t.Run("Simple", func(t *testing.T) {
+			_, err := c.PolicyCreateOrUpdate(ctx, "example-main", []byte(`
+package system
+
+main = input.value
+`))
+			require.NoError(t, err)
+			defer c.PolicyDelete(ctx, "example-main")
+
+			b, err := c.QuerySimple(ctx, "/", map[string]interface{}{"value": 42})
+			require.NoError(t, err)
+			assert.Equal(t, "42", string(b))
+		})
+
+		t.Run("AdHoc", func(t *testing.T) {
+			res, err := c.QueryAdHoc(ctx, "", gopa.QueryAdHocOptions{
+				Query: "x := input.values[_]; x > 1",
+				Input: map[string]interface{}{
+					"values": []interface{}{1, 2, 3},
+				},
+			})
+			require.NoError(t, err)
+			assert.Equal(t, types.AdhocQueryResultSetV1{
+				{"x": float64(2)},
+				{"x": float64(3)},
+			}, res.Result)
+
+			_, err = c.QueryAdHoc(ctx, "", gopa.QueryAdHocOptions{Query: "x := "})
+			assert.True(t, gopa.IsInvalidParameter(err))
+		})
+	})
+
+	t.Run("CompilePartial", func(t *testing.T) {
+		res, err := c.CompilePartial(ctx, gopa.CompileOptions{
+			Query:    "data.opa.examples.allow_request == true",
+			Unknowns: []string{"input"},
+		})
+		require.NoError(t, err)
+		require.Len(t, res.Result.Queries, 1)
+		assert.Equal(t, "true = input.example.flag", res.Result.Queries[0].String())
+	})
+
+	t.Run("Health", func(t *testing.T) {
+		res, err := c.Health(ctx, gopa.HealthOptions{})
+		require.NoError(t, err)
+		assert.True(t, res.Healthy())
+	})
+
+	t.Run("SetStore", func(t *testing.T) {
+		store := inmem.NewFromObject(map[string]interface{}{
+			"example": map[string]interface{}{"key": "value"},
+		})
+
+		c, err := gopa.NewEmbeddedClient(gopa.SetStore(store))
+		require.NoError(t, err)
+
+		res, err := c.DataGet(ctx, "example/key")
+		require.NoError(t, err)
+		assert.Equal(t, "value", *res.Result)
+	})
+}
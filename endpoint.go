@@ -0,0 +1,311 @@
+package gopa
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// Balancer is the strategy used to pick the
+// endpoint that receives each request
+type Balancer string
+
+// List of the possible Balancer
+const (
+	// BalancerRoundRobin sends the requests to each endpoint in turn
+	BalancerRoundRobin Balancer = "round_robin"
+	// BalancerLeastOutstanding sends the requests to the
+	// endpoint with less requests in progress
+	BalancerLeastOutstanding Balancer = "least_outstanding"
+)
+
+// EjectionPolicy configures when an endpoint stops
+// receiving requests because it's failing
+type EjectionPolicy struct {
+	// MaxFailures is the number of consecutive failures, connection
+	// errors or 502, 503 and 504, after which the endpoint is ejected
+	MaxFailures int
+	// Cooldown is the time the endpoint is ejected, once re-admitted
+	// a new failure ejects it again until it has a success
+	Cooldown time.Duration
+}
+
+// DefaultEjectionPolicy is the EjectionPolicy used
+// when none is set
+var DefaultEjectionPolicy = EjectionPolicy{
+	MaxFailures: 3,
+	Cooldown:    30 * time.Second,
+}
+
+// EndpointStatus is the state of one of the endpoints
+type EndpointStatus struct {
+	URL string
+	// Outstanding is the number of requests in progress
+	Outstanding int
+	// Failures is the number of consecutive failures
+	Failures int
+	// Ejected is true when it's not receiving requests
+	Ejected bool
+	// EjectedUntil is when it'll be re-admitted, it's zero
+	// if it was ejected by CheckEndpoints, as then it's
+	// re-admitted when a check reports it healthy
+	EjectedUntil time.Time
+}
+
+// endpoint is one of the OPA servers of the endpointPool
+type endpoint struct {
+	url          *url.URL
+	outstanding  int
+	failures     int
+	ejectedUntil time.Time
+	unhealthy    bool
+}
+
+// available checks if the endpoint can receive requests at now
+func (e *endpoint) available(now time.Time) bool {
+	return !e.unhealthy && !now.Before(e.ejectedUntil)
+}
+
+// endpointPool balances the requests between the endpoints
+type endpointPool struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+	balancer  Balancer
+	ejection  EjectionPolicy
+	next      int
+}
+
+// getPool returns the endpointPool of the Client
+// initializing it with the defaults if needed
+func (c *Client) getPool() *endpointPool {
+	if c.pool == nil {
+		c.pool = &endpointPool{
+			balancer: BalancerRoundRobin,
+			ejection: DefaultEjectionPolicy,
+		}
+	}
+	return c.pool
+}
+
+// errSetURLAndEndpoints is returned when both SetURL and
+// SetEndpoints are used, as only one of them can be applied
+var errSetURLAndEndpoints = errors.New("SetURL and SetEndpoints can not be used together")
+
+// SetEndpoints sets the URLs of several OPA servers with the same
+// policies and data, the requests are distributed between them with
+// the Balancer. It can not be used with SetURL. The Health checks
+// only the first one, use CheckEndpoints to check all of them
+func SetEndpoints(urls ...string) ClientOptionFunc {
+	return func(c *Client) error {
+		if len(urls) == 0 {
+			return errors.New("at least one endpoint is required")
+		}
+		if c.url != nil && (c.pool == nil || len(c.pool.endpoints) == 0) {
+			return errSetURLAndEndpoints
+		}
+
+		p := c.getPool()
+		p.endpoints = make([]*endpoint, 0, len(urls))
+		for _, u := range urls {
+			pu, err := url.Parse(u)
+			if err != nil {
+				return err
+			}
+			p.endpoints = append(p.endpoints, &endpoint{url: pu})
+		}
+		c.url = p.endpoints[0].url
+
+		return nil
+	}
+}
+
+// SetBalancer sets the b as the strategy to distribute the
+// requests between the endpoints, by default BalancerRoundRobin
+func SetBalancer(b Balancer) ClientOptionFunc {
+	return func(c *Client) error {
+		if b != BalancerRoundRobin && b != BalancerLeastOutstanding {
+			return errors.New("invalid Balancer " + string(b))
+		}
+		c.getPool().balancer = b
+		return nil
+	}
+}
+
+// SetEjectionPolicy sets the ep as the policy to eject the failing
+// endpoints, by default DefaultEjectionPolicy
+func SetEjectionPolicy(ep EjectionPolicy) ClientOptionFunc {
+	return func(c *Client) error {
+		if ep.MaxFailures < 1 {
+			return errors.New("the MaxFailures of the EjectionPolicy has to be at least 1")
+		}
+		c.getPool().ejection = ep
+		return nil
+	}
+}
+
+// Endpoints returns the status of each one of the
+// endpoints, it's empty if SetEndpoints was not used
+func (c *Client) Endpoints() []EndpointStatus {
+	return c.pool.status()
+}
+
+// CheckEndpoints checks the health of each one of the endpoints with
+// the opt. The unhealthy ones are ejected until a later check reports
+// them healthy, which also re-admits the ones ejected by failures
+// https://www.openpolicyagent.org/docs/latest/rest-api/#health-api
+func (c *Client) CheckEndpoints(ctx context.Context, opt HealthOptions) ([]EndpointStatus, error) {
+	if c.pool == nil {
+		return nil, nil
+	}
+
+	for _, e := range c.pool.endpoints {
+		hr, err := c.healthsvc.check(ctx, e.url, opt)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		c.pool.setHealthy(e, hr.Healthy())
+	}
+
+	return c.pool.status(), nil
+}
+
+// WatchEndpoints calls CheckEndpoints every interval until the
+// ctx is done, which is the error returned. It's meant to be
+// run on a goroutine
+func (c *Client) WatchEndpoints(ctx context.Context, interval time.Duration, opt HealthOptions) error {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+
+	for {
+		if _, err := c.CheckEndpoints(ctx, opt); err != nil && ctx.Err() == nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
+
+// pick returns the endpoint for the next request, it's nil if there
+// are no endpoints. When all of them are ejected it picks between
+// all of them, as failing is better than not trying
+func (p *endpointPool) pick() *endpoint {
+	if p == nil || len(p.endpoints) == 0 {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	available := make([]*endpoint, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		if e.available(now) {
+			available = append(available, e)
+		}
+	}
+	if len(available) == 0 {
+		available = p.endpoints
+	}
+
+	var picked *endpoint
+	switch p.balancer {
+	case BalancerLeastOutstanding:
+		// It starts on a different one each time
+		// so the ties are also distributed
+		for i := range available {
+			e := available[(p.next+i)%len(available)]
+			if picked == nil || e.outstanding < picked.outstanding {
+				picked = e
+			}
+		}
+	default:
+		picked = available[p.next%len(available)]
+	}
+	p.next++
+
+	picked.outstanding++
+
+	return picked
+}
+
+// done records the result of the request sent to the e
+// picked before, ejecting it if it failed too many times
+func (p *endpointPool) done(ctx context.Context, e *endpoint, res *http.Response, err error) {
+	if e == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.outstanding--
+
+	// If the ctx is done the error is not
+	// a failure of the endpoint
+	if ctx.Err() != nil {
+		return
+	}
+
+	if err == nil {
+		switch res.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		default:
+			e.failures = 0
+			return
+		}
+	}
+
+	e.failures++
+	if e.failures >= p.ejection.MaxFailures {
+		e.ejectedUntil = time.Now().Add(p.ejection.Cooldown)
+	}
+}
+
+// setHealthy sets the result of the health check of the e
+func (p *endpointPool) setHealthy(e *endpoint, healthy bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e.unhealthy = !healthy
+	if healthy {
+		e.failures = 0
+		e.ejectedUntil = time.Time{}
+	}
+}
+
+// status returns the EndpointStatus of all the endpoints
+func (p *endpointPool) status() []EndpointStatus {
+	if p == nil {
+		return nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	ss := make([]EndpointStatus, 0, len(p.endpoints))
+	for _, e := range p.endpoints {
+		s := EndpointStatus{
+			URL:         e.url.String(),
+			Outstanding: e.outstanding,
+			Failures:    e.failures,
+			Ejected:     !e.available(now),
+		}
+		if !e.unhealthy && s.Ejected {
+			s.EjectedUntil = e.ejectedUntil
+		}
+		ss = append(ss, s)
+	}
+
+	return ss
+}
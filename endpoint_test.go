@@ -0,0 +1,209 @@
+package gopa_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// endpointServer is an OPA replica that counts the
+// requests and that can be turned down
+type endpointServer struct {
+	*httptest.Server
+	hits    int32
+	down    int32
+	release chan struct{}
+}
+
+func newEndpointServer() *endpointServer {
+	es := &endpointServer{}
+	es.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&es.down) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/health" {
+			return
+		}
+		atomic.AddInt32(&es.hits, 1)
+		if es.release != nil {
+			<-es.release
+		}
+		w.Write([]byte(`{"result": true}`))
+	}))
+	return es
+}
+
+func TestClientEndpoints(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("RoundRobin", func(t *testing.T) {
+		s1, s2, s3 := newEndpointServer(), newEndpointServer(), newEndpointServer()
+		defer s1.Close()
+		defer s2.Close()
+		defer s3.Close()
+
+		c, err := gopa.NewClient(gopa.SetEndpoints(s1.URL, s2.URL, s3.URL))
+		require.NoError(t, err)
+
+		for i := 0; i < 9; i++ {
+			_, err := c.DataGet(ctx, "example")
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, int32(3), atomic.LoadInt32(&s1.hits))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&s2.hits))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&s3.hits))
+	})
+
+	t.Run("LeastOutstanding", func(t *testing.T) {
+		s1, s2 := newEndpointServer(), newEndpointServer()
+		defer s1.Close()
+		defer s2.Close()
+		s1.release = make(chan struct{})
+
+		c, err := gopa.NewClient(
+			gopa.SetEndpoints(s1.URL, s2.URL),
+			gopa.SetBalancer(gopa.BalancerLeastOutstanding),
+		)
+		require.NoError(t, err)
+
+		// The first request stays in progress on s1
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.DataGet(ctx, "example")
+		}()
+		require.Eventually(t, func() bool { return atomic.LoadInt32(&s1.hits) == 1 }, time.Second, time.Millisecond)
+
+		for i := 0; i < 3; i++ {
+			_, err := c.DataGet(ctx, "example")
+			require.NoError(t, err)
+		}
+
+		close(s1.release)
+		wg.Wait()
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(&s1.hits))
+		assert.Equal(t, int32(3), atomic.LoadInt32(&s2.hits))
+	})
+
+	t.Run("Ejection", func(t *testing.T) {
+		s1, s2 := newEndpointServer(), newEndpointServer()
+		defer s1.Close()
+		defer s2.Close()
+		atomic.StoreInt32(&s1.down, 1)
+
+		c, err := gopa.NewClient(
+			gopa.SetEndpoints(s1.URL, s2.URL),
+			gopa.SetEjectionPolicy(gopa.EjectionPolicy{MaxFailures: 2, Cooldown: 50 * time.Millisecond}),
+		)
+		require.NoError(t, err)
+
+		var failed int
+		for i := 0; i < 10; i++ {
+			if _, err := c.DataGet(ctx, "example"); err != nil {
+				failed++
+			}
+		}
+		assert.Equal(t, 2, failed, "It's ejected after MaxFailures")
+		assert.Equal(t, int32(8), atomic.LoadInt32(&s2.hits))
+
+		es := c.Endpoints()
+		require.Len(t, es, 2)
+		assert.True(t, es[0].Ejected)
+		assert.Equal(t, 2, es[0].Failures)
+		assert.False(t, es[0].EjectedUntil.IsZero())
+		assert.False(t, es[1].Ejected)
+
+		// After the Cooldown it's re-admitted
+		atomic.StoreInt32(&s1.down, 0)
+		time.Sleep(60 * time.Millisecond)
+
+		for i := 0; i < 2; i++ {
+			_, err := c.DataGet(ctx, "example")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&s1.hits))
+		assert.False(t, c.Endpoints()[0].Ejected)
+		assert.Equal(t, 0, c.Endpoints()[0].Failures)
+	})
+
+	t.Run("CheckEndpoints", func(t *testing.T) {
+		s1, s2 := newEndpointServer(), newEndpointServer()
+		defer s1.Close()
+		defer s2.Close()
+		atomic.StoreInt32(&s2.down, 1)
+
+		c, err := gopa.NewClient(gopa.SetEndpoints(s1.URL, s2.URL))
+		require.NoError(t, err)
+
+		es, err := c.CheckEndpoints(ctx, gopa.HealthOptions{})
+		require.NoError(t, err)
+		require.Len(t, es, 2)
+		assert.False(t, es[0].Ejected)
+		assert.True(t, es[1].Ejected)
+		assert.True(t, es[1].EjectedUntil.IsZero())
+
+		for i := 0; i < 4; i++ {
+			_, err := c.DataGet(ctx, "example")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(4), atomic.LoadInt32(&s1.hits))
+
+		// It's re-admitted once healthy
+		atomic.StoreInt32(&s2.down, 0)
+		es, err = c.CheckEndpoints(ctx, gopa.HealthOptions{})
+		require.NoError(t, err)
+		assert.False(t, es[1].Ejected)
+
+		for i := 0; i < 4; i++ {
+			_, err := c.DataGet(ctx, "example")
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(2), atomic.LoadInt32(&s2.hits))
+	})
+
+	t.Run("AllEjected", func(t *testing.T) {
+		s1 := newEndpointServer()
+		defer s1.Close()
+		atomic.StoreInt32(&s1.down, 1)
+
+		c, err := gopa.NewClient(gopa.SetEndpoints(s1.URL))
+		require.NoError(t, err)
+
+		_, err = c.CheckEndpoints(ctx, gopa.HealthOptions{})
+		require.NoError(t, err)
+
+		atomic.StoreInt32(&s1.down, 0)
+		_, err = c.DataGet(ctx, "example")
+		require.NoError(t, err, "When all are ejected they are still tried")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := gopa.NewClient(gopa.SetEndpoints())
+		assert.Error(t, err)
+
+		_, err = gopa.NewClient(gopa.SetBalancer("potato"))
+		assert.Error(t, err)
+
+		_, err = gopa.NewClient(gopa.SetEjectionPolicy(gopa.EjectionPolicy{}))
+		assert.Error(t, err)
+
+		// Only one of them can be applied, in any order
+		_, err = gopa.NewClient(gopa.SetURL(gopa.DefaultURL), gopa.SetEndpoints(gopa.DefaultURL))
+		assert.EqualError(t, err, "SetURL and SetEndpoints can not be used together")
+
+		_, err = gopa.NewClient(gopa.SetEndpoints(gopa.DefaultURL), gopa.SetURL(gopa.DefaultURL))
+		assert.EqualError(t, err, "SetURL and SetEndpoints can not be used together")
+	})
+}
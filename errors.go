@@ -0,0 +1,118 @@
+package gopa
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// maxErrorBody is the max length of the body
+// printed on the ResponseError
+const maxErrorBody = 256
+
+// ResponseError is the error returned when OPA
+// replies with a non 2XX status
+type ResponseError struct {
+	StatusCode int
+	Method     string
+	Path       string
+	// Body is the raw body of the response
+	Body []byte
+	// APIError is the error sent by OPA, it's nil if the
+	// body was not an OPA error, like an HTML page from a proxy
+	APIError *APIError
+}
+
+// Error transforms the error into a string
+func (e *ResponseError) Error() string {
+	if e.APIError != nil {
+		return fmt.Sprintf("%s %s: %d %s", e.Method, e.Path, e.StatusCode, e.APIError.Error())
+	}
+
+	body := string(e.Body)
+	if len(body) > maxErrorBody {
+		body = body[:maxErrorBody] + "..."
+	}
+
+	return fmt.Sprintf("%s %s: %d %s: %s", e.Method, e.Path, e.StatusCode, http.StatusText(e.StatusCode), body)
+}
+
+// Unwrap returns the APIError so it can be used with errors.As
+func (e *ResponseError) Unwrap() error {
+	if e.APIError == nil {
+		return nil
+	}
+	return e.APIError
+}
+
+// code returns the OPA code of the error, it's
+// empty if it was not a ResponseError from OPA
+func code(err error) string {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Code
+	}
+	return ""
+}
+
+// statusCode returns the HTTP status of the error, it's
+// 0 if it was not a ResponseError
+func statusCode(err error) int {
+	var resErr *ResponseError
+	if errors.As(err, &resErr) {
+		return resErr.StatusCode
+	}
+	return 0
+}
+
+// IsNotFound checks if the err is due to the resource
+// not being found
+func IsNotFound(err error) bool {
+	return code(err) == types.CodeResourceNotFound || statusCode(err) == http.StatusNotFound
+}
+
+// IsUnauthorized checks if the err is due to the
+// request not being authorized
+func IsUnauthorized(err error) bool {
+	return code(err) == types.CodeUnauthorized || statusCode(err) == http.StatusUnauthorized
+}
+
+// IsInvalidParameter checks if the err is due to an
+// invalid parameter, like a policy that does not compile
+func IsInvalidParameter(err error) bool {
+	return code(err) == types.CodeInvalidParameter
+}
+
+// IsCompileError checks if the err is due to an error
+// parsing or compiling Rego
+func IsCompileError(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+
+	if isRegoError(apiErr.Code) {
+		return true
+	}
+
+	for _, e := range apiErr.Errors {
+		if isRegoError(e.Code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isRegoError checks if the code is of one of the
+// Rego parse or compile errors
+func isRegoError(code string) bool {
+	switch code {
+	case ast.ParseErr, ast.CompileErr, ast.TypeErr, ast.UnsafeVarErr, ast.RecursionErr:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+package gopa_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/open-policy-agent/opa/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseError(t *testing.T) {
+	t.Run("NotFound", func(t *testing.T) {
+		c, err := gopa.NewClient()
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		_, err = c.PolicyGet(ctx, "invalidID")
+		require.Error(t, err)
+		assert.True(t, gopa.IsNotFound(err))
+		assert.False(t, gopa.IsInvalidParameter(err))
+		assert.False(t, gopa.IsCompileError(err))
+
+		var resErr *gopa.ResponseError
+		require.True(t, errors.As(err, &resErr))
+		assert.Equal(t, http.StatusNotFound, resErr.StatusCode)
+		assert.Equal(t, http.MethodGet, resErr.Method)
+		assert.Equal(t, "/v1/policies/invalidID", resErr.Path)
+		assert.NotEmpty(t, resErr.Body)
+
+		var apiErr *gopa.APIError
+		require.True(t, errors.As(err, &apiErr))
+		assert.Equal(t, types.CodeResourceNotFound, apiErr.Code)
+	})
+
+	t.Run("CompileError", func(t *testing.T) {
+		c, err := gopa.NewClient()
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		_, err = c.PolicyCreateOrUpdate(ctx, "example-errors", []byte(`potato`))
+		require.Error(t, err)
+		assert.True(t, gopa.IsInvalidParameter(err))
+		assert.True(t, gopa.IsCompileError(err))
+		assert.False(t, gopa.IsNotFound(err))
+	})
+
+	t.Run("ParseErrorDetails", func(t *testing.T) {
+		// The details of the parse errors are an object
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"invalid_parameter","message":"error(s) occurred while compiling module(s)","errors":[{"code":"rego_parse_error","message":"unexpected eof token","location":{"file":"example","row":3,"col":3},"details":{"line":"x {","idx":2}}]}`))
+		}))
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		_, err = c.PolicyCreateOrUpdate(ctx, "example", []byte("package a\n\nx {"))
+		require.Error(t, err)
+		assert.True(t, gopa.IsInvalidParameter(err))
+		assert.True(t, gopa.IsCompileError(err))
+
+		var apiErr *gopa.APIError
+		require.True(t, errors.As(err, &apiErr))
+		require.Len(t, apiErr.Errors, 1)
+		assert.Equal(t, map[string]interface{}{"line": "x {", "idx": float64(2)}, apiErr.Errors[0].Details)
+	})
+
+	t.Run("Unauthorized", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnauthorized)
+			w.Write([]byte(`{"code":"unauthorized","message":"request rejected by administrative policy"}`))
+		}))
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		_, err = c.PolicyList(ctx)
+		require.Error(t, err)
+		assert.True(t, gopa.IsUnauthorized(err))
+		assert.EqualError(t, err, "GET /v1/policies: 401 unauthorized: request rejected by administrative policy")
+	})
+
+	t.Run("NotJSON", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`<html><body>Bad Gateway</body></html>`))
+		}))
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		_, err = c.DataGet(ctx, "potato")
+		require.Error(t, err)
+
+		var resErr *gopa.ResponseError
+		require.True(t, errors.As(err, &resErr))
+		assert.Equal(t, http.StatusBadGateway, resErr.StatusCode)
+		assert.Nil(t, resErr.APIError)
+		assert.Equal(t, []byte(`<html><body>Bad Gateway</body></html>`), resErr.Body)
+		assert.EqualError(t, err, "GET /v1/data/potato: 502 Bad Gateway: <html><body>Bad Gateway</body></html>")
+
+		var apiErr *gopa.APIError
+		assert.False(t, errors.As(err, &apiErr))
+	})
+}
@@ -1,8 +1,21 @@
 module github.com/cycloidio/gopa
 
-go 1.15
+go 1.18
 
 require (
 	github.com/open-policy-agent/opa v0.23.2
 	github.com/stretchr/testify v1.6.1
 )
+
+require (
+	github.com/OneOfOne/xxhash v1.2.7 // indirect
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/ghodss/yaml v0.0.0-20180820084758-c7ce16629ff4 // indirect
+	github.com/gobwas/glob v0.2.3 // indirect
+	github.com/pkg/errors v0.0.0-20181023235946-059132a15dd0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	github.com/rcrowley/go-metrics v0.0.0-20181016184325-3113b8401b8a // indirect
+	github.com/yashtewari/glob-intersection v0.0.0-20180916065949-5c77d914dd0b // indirect
+	gopkg.in/yaml.v2 v2.2.1 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
@@ -0,0 +1,131 @@
+package gopa
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// HealthService is the service in charge of
+// the Health interactions
+type HealthService struct {
+	client *Client
+	path   string
+}
+
+// NewHealthService initializes a new HealthService
+func NewHealthService(c *Client) *HealthService {
+	return &HealthService{
+		client: c,
+		path:   "/health",
+	}
+}
+
+// HealthStatus is the status of the OPA server
+type HealthStatus string
+
+// List of the possible HealthStatus
+const (
+	// HealthStatusHealthy means OPA is up and ready to serve
+	HealthStatusHealthy HealthStatus = "healthy"
+	// HealthStatusNotReady means OPA is up but some of the
+	// checks (evaluation, bundles or plugins) are not OK
+	HealthStatusNotReady HealthStatus = "not_ready"
+	// HealthStatusUnreachable means OPA could not be reached
+	HealthStatusUnreachable HealthStatus = "unreachable"
+)
+
+// HealthOptions are the options available to the Check
+type HealthOptions struct {
+	// Bundles checks that all the configured bundles
+	// have been activated
+	Bundles bool
+	// Plugins checks that all the plugins are in OK state
+	Plugins bool
+	// ExcludePlugins are the plugins to ignore from
+	// the Plugins check
+	ExcludePlugins []string
+}
+
+// query returns the URL parameters of the options
+func (o HealthOptions) query() url.Values {
+	q := make(url.Values)
+	if o.Bundles {
+		q.Set(types.ParamBundlesActivationV1, "true")
+	}
+	if o.Plugins {
+		q.Set(types.ParamPluginsV1, "true")
+	}
+	for _, p := range o.ExcludePlugins {
+		q.Add("exclude-plugin", p)
+	}
+	return q
+}
+
+// HealthResponse is the result of a health check
+type HealthResponse struct {
+	Status HealthStatus
+	// StatusCode is the HTTP status returned by OPA, it's
+	// 0 when it was unreachable
+	StatusCode int
+	// Message is the reason given by OPA when it's not ready
+	Message string
+	// Err is the error of the request when it's unreachable
+	Err error
+}
+
+// Healthy returns if OPA is up and ready to serve
+func (hr *HealthResponse) Healthy() bool {
+	return hr.Status == HealthStatusHealthy
+}
+
+// Check checks if OPA is healthy with the given opt. Failing to
+// reach OPA is not returned as an error but as HealthStatusUnreachable.
+// With SetEndpoints only the first one is checked, the
+// Client.CheckEndpoints checks all of them
+// https://www.openpolicyagent.org/docs/latest/rest-api/#health-api
+func (hs *HealthService) Check(ctx context.Context, opt HealthOptions) (*HealthResponse, error) {
+	return hs.check(ctx, hs.client.url, opt)
+}
+
+// check checks the health of the OPA on the base URL u
+func (hs *HealthService) check(ctx context.Context, u *url.URL, opt HealthOptions) (*HealthResponse, error) {
+	req, err := hs.client.request(ctx, u, http.MethodGet, hs.path, opt.query(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := hs.client.client.Do(req)
+	if err != nil {
+		return &HealthResponse{
+			Status: HealthStatusUnreachable,
+			Err:    err,
+		}, nil
+	}
+	defer res.Body.Close()
+
+	hr := &HealthResponse{
+		Status:     HealthStatusHealthy,
+		StatusCode: res.StatusCode,
+	}
+
+	if res.StatusCode != http.StatusOK {
+		hr.Status = HealthStatusNotReady
+
+		// Newer versions of OPA return the reason as
+		// {"error": "..."} so we try to read it
+		var body struct {
+			Error string `json:"error"`
+		}
+		b, err := ioutil.ReadAll(res.Body)
+		if err == nil && json.Unmarshal(b, &body) == nil {
+			hr.Message = body.Error
+		}
+	}
+
+	return hr, nil
+}
@@ -0,0 +1,69 @@
+package gopa_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthService(t *testing.T) {
+	t.Run("Healthy", func(t *testing.T) {
+		c, err := gopa.NewClient()
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		res, err := c.Health(ctx, gopa.HealthOptions{
+			Bundles:        true,
+			Plugins:        true,
+			ExcludePlugins: []string{"decision_logs"},
+		})
+		require.NoError(t, err)
+		assert.True(t, res.Healthy())
+		assert.Equal(t, gopa.HealthStatusHealthy, res.Status)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+	})
+
+	t.Run("NotReady", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/health", r.URL.Path)
+			assert.Equal(t, "true", r.URL.Query().Get("bundles"))
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":"not all configured bundles have been activated"}`))
+		}))
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		res, err := c.Health(ctx, gopa.HealthOptions{Bundles: true})
+		require.NoError(t, err)
+		assert.False(t, res.Healthy())
+		assert.Equal(t, gopa.HealthStatusNotReady, res.Status)
+		assert.Equal(t, http.StatusInternalServerError, res.StatusCode)
+		assert.Equal(t, "not all configured bundles have been activated", res.Message)
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		res, err := c.Health(ctx, gopa.HealthOptions{})
+		require.NoError(t, err)
+		assert.False(t, res.Healthy())
+		assert.Equal(t, gopa.HealthStatusUnreachable, res.Status)
+		assert.Error(t, res.Err)
+	})
+}
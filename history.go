@@ -0,0 +1,297 @@
+package gopa
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// ErrVersionNotFound is returned when the
+// version of a policy is not on the HistoryStore
+var ErrVersionNotFound = errors.New("policy version not found")
+
+// PolicyVersion is a version of a policy written through the PolicyHistory
+type PolicyVersion struct {
+	ID string `json:"id"`
+	// Version is the number of the version, starting at 1
+	Version int `json:"version"`
+	// Hash is the SHA-256 of the Raw
+	Hash      string    `json:"hash"`
+	Raw       string    `json:"raw"`
+	CreatedAt time.Time `json:"created_at"`
+	Author    string    `json:"author,omitempty"`
+	// Metadata is any other information of the change,
+	// like the commit it comes from
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Deleted is true when the policy was deleted
+	Deleted bool `json:"deleted,omitempty"`
+	// Rollback is the version restored when it's a rollback
+	Rollback int `json:"rollback,omitempty"`
+}
+
+// HistoryStore is where the PolicyHistory saves the versions
+type HistoryStore interface {
+	// Append saves the v setting its Version to the next one
+	Append(ctx context.Context, v *PolicyVersion) error
+	// List returns all the versions of the policy
+	// with the id, from the oldest to the newest
+	List(ctx context.Context, id string) ([]PolicyVersion, error)
+}
+
+// PolicyHistory writes the policies to a Service recording each
+// version on a HistoryStore, so they can be rolled back
+type PolicyHistory struct {
+	service Service
+	store   HistoryStore
+}
+
+// NewPolicyHistory initializes a new PolicyHistory that
+// writes to s and records the versions on store
+func NewPolicyHistory(s Service, store HistoryStore) *PolicyHistory {
+	return &PolicyHistory{
+		service: s,
+		store:   store,
+	}
+}
+
+// VersionOptionFunc is a type used to set the
+// information of the PolicyVersion of a change
+type VersionOptionFunc func(*PolicyVersion)
+
+// WithAuthor sets the author of the change
+func WithAuthor(author string) VersionOptionFunc {
+	return func(v *PolicyVersion) {
+		v.Author = author
+	}
+}
+
+// WithMetadata adds the key with the value to the Metadata of the change
+func WithMetadata(key, value string) VersionOptionFunc {
+	return func(v *PolicyVersion) {
+		if v.Metadata == nil {
+			v.Metadata = make(map[string]string)
+		}
+		v.Metadata[key] = value
+	}
+}
+
+// CreateOrUpdate creates or updates the policy with the id and
+// the content policy, and records it as a new version
+func (h *PolicyHistory) CreateOrUpdate(ctx context.Context, id string, policy []byte, opts ...VersionOptionFunc) (*PolicyVersion, error) {
+	_, err := h.service.PolicyCreateOrUpdate(ctx, id, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	return h.record(ctx, newPolicyVersion(id, policy, opts))
+}
+
+// Delete deletes the policy with the id and records the deletion as a new version
+func (h *PolicyHistory) Delete(ctx context.Context, id string, opts ...VersionOptionFunc) (*PolicyVersion, error) {
+	_, err := h.service.PolicyDelete(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	v := newPolicyVersion(id, nil, opts)
+	v.Deleted = true
+
+	return h.record(ctx, v)
+}
+
+// History returns all the versions of the policy
+// with the id, from the oldest to the newest
+func (h *PolicyHistory) History(ctx context.Context, id string) ([]PolicyVersion, error) {
+	return h.store.List(ctx, id)
+}
+
+// Rollback writes the content of the version of the policy with the
+// id, or deletes it if it was a deletion, and records it as a new
+// version. The History can be used to find the last good version
+func (h *PolicyHistory) Rollback(ctx context.Context, id string, version int, opts ...VersionOptionFunc) (*PolicyVersion, error) {
+	vs, err := h.store.List(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if version < 1 || version > len(vs) {
+		return nil, fmt.Errorf("%w: %s version %d", ErrVersionNotFound, id, version)
+	}
+	target := vs[version-1]
+
+	v := newPolicyVersion(id, []byte(target.Raw), opts)
+	v.Rollback = version
+	v.Deleted = target.Deleted
+
+	if target.Deleted {
+		_, err = h.service.PolicyDelete(ctx, id)
+		if IsNotFound(err) {
+			err = nil
+		}
+	} else {
+		_, err = h.service.PolicyCreateOrUpdate(ctx, id, []byte(target.Raw))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return h.record(ctx, v)
+}
+
+// record appends the v to the store
+func (h *PolicyHistory) record(ctx context.Context, v *PolicyVersion) (*PolicyVersion, error) {
+	err := h.store.Append(ctx, v)
+	if err != nil {
+		return nil, fmt.Errorf("the policy %q was written but its version could not be recorded: %w", v.ID, err)
+	}
+
+	return v, nil
+}
+
+// newPolicyVersion returns the PolicyVersion of the policy
+// with the id, the opts are applied to it
+func newPolicyVersion(id string, policy []byte, opts []VersionOptionFunc) *PolicyVersion {
+	sum := sha256.Sum256(policy)
+	v := &PolicyVersion{
+		ID:        id,
+		Hash:      hex.EncodeToString(sum[:]),
+		Raw:       string(policy),
+		CreatedAt: time.Now().UTC(),
+	}
+	for _, o := range opts {
+		o(v)
+	}
+
+	return v
+}
+
+// MemoryHistoryStore is a HistoryStore that keeps the versions in memory
+type MemoryHistoryStore struct {
+	mu       sync.RWMutex
+	versions map[string][]PolicyVersion
+}
+
+// NewMemoryHistoryStore initializes a new empty MemoryHistoryStore
+func NewMemoryHistoryStore() *MemoryHistoryStore {
+	return &MemoryHistoryStore{
+		versions: make(map[string][]PolicyVersion),
+	}
+}
+
+// Append saves the v setting its Version to the next one
+func (s *MemoryHistoryStore) Append(ctx context.Context, v *PolicyVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	v.Version = len(s.versions[v.ID]) + 1
+	s.versions[v.ID] = append(s.versions[v.ID], *v)
+
+	return nil
+}
+
+// List returns all the versions of the policy
+// with the id, from the oldest to the newest
+func (s *MemoryHistoryStore) List(ctx context.Context, id string) ([]PolicyVersion, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return append([]PolicyVersion(nil), s.versions[id]...), nil
+}
+
+// FileHistoryStore is a HistoryStore that keeps the versions of
+// each policy on a file of a directory, with one JSON per line
+type FileHistoryStore struct {
+	mu  sync.Mutex
+	dir string
+}
+
+// NewFileHistoryStore initializes a new FileHistoryStore
+// on the dir, which is created if it does not exist
+func NewFileHistoryStore(dir string) (*FileHistoryStore, error) {
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileHistoryStore{
+		dir: dir,
+	}, nil
+}
+
+// Append saves the v setting its Version to the next one
+func (s *FileHistoryStore) Append(ctx context.Context, v *PolicyVersion) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vs, err := s.list(v.ID)
+	if err != nil {
+		return err
+	}
+	v.Version = len(vs) + 1
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(s.path(v.ID), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(b, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// List returns all the versions of the policy
+// with the id, from the oldest to the newest
+func (s *FileHistoryStore) List(ctx context.Context, id string) ([]PolicyVersion, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list(id)
+}
+
+// list reads all the versions of the policy with the id
+func (s *FileHistoryStore) list(id string) ([]PolicyVersion, error) {
+	b, err := ioutil.ReadFile(s.path(id))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var vs []PolicyVersion
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	sc.Buffer(nil, len(b)+1)
+	for sc.Scan() {
+		var v PolicyVersion
+		if err := json.Unmarshal(sc.Bytes(), &v); err != nil {
+			return nil, err
+		}
+		vs = append(vs, v)
+	}
+
+	return vs, sc.Err()
+}
+
+// path returns the file of the policy with the id, which is
+// escaped as the IDs of the policies can have '/'
+func (s *FileHistoryStore) path(id string) string {
+	return filepath.Join(s.dir, url.PathEscape(id)+".jsonl")
+}
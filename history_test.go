@@ -0,0 +1,89 @@
+package gopa_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyHistory(t *testing.T) {
+	ctx := context.Background()
+
+	fs, err := gopa.NewFileHistoryStore(filepath.Join(t.TempDir(), "history"))
+	require.NoError(t, err)
+
+	for name, store := range map[string]gopa.HistoryStore{
+		"Memory": gopa.NewMemoryHistoryStore(),
+		"File":   fs,
+	} {
+		t.Run(name, func(t *testing.T) {
+			c, err := gopa.NewEmbeddedClient()
+			require.NoError(t, err)
+
+			h := gopa.NewPolicyHistory(c, store)
+
+			id := "policies/example.rego"
+			good := []byte("package opa.history\n\nallow = true\n")
+			bad := []byte("package opa.history\n\nallow = false\n")
+
+			v, err := h.CreateOrUpdate(ctx, id, good, gopa.WithAuthor("alice"), gopa.WithMetadata("commit", "abc"))
+			require.NoError(t, err)
+			assert.Equal(t, 1, v.Version)
+			assert.Equal(t, "alice", v.Author)
+			assert.Equal(t, map[string]string{"commit": "abc"}, v.Metadata)
+			assert.Len(t, v.Hash, 64)
+
+			v, err = h.CreateOrUpdate(ctx, id, bad, gopa.WithAuthor("bob"))
+			require.NoError(t, err)
+			assert.Equal(t, 2, v.Version)
+
+			_, err = h.CreateOrUpdate(ctx, id, []byte("potato"))
+			require.Error(t, err)
+
+			v, err = h.Rollback(ctx, id, 1, gopa.WithAuthor("alice"))
+			require.NoError(t, err)
+			assert.Equal(t, 3, v.Version)
+			assert.Equal(t, 1, v.Rollback)
+
+			res, err := c.DataGet(ctx, "opa/history/allow")
+			require.NoError(t, err)
+			assert.Equal(t, true, *res.Result)
+
+			_, err = h.Delete(ctx, id)
+			require.NoError(t, err)
+
+			vs, err := h.History(ctx, id)
+			require.NoError(t, err)
+			require.Len(t, vs, 4, "The failed writes are not recorded")
+			assert.Equal(t, string(good), vs[0].Raw)
+			assert.Equal(t, string(bad), vs[1].Raw)
+			assert.Equal(t, "bob", vs[1].Author)
+			assert.Equal(t, vs[0].Hash, vs[2].Hash)
+			assert.True(t, vs[3].Deleted)
+			assert.False(t, vs[3].CreatedAt.Before(vs[0].CreatedAt))
+
+			_, err = h.Rollback(ctx, id, 2)
+			require.NoError(t, err)
+			res, err = c.DataGet(ctx, "opa/history/allow")
+			require.NoError(t, err)
+			assert.Equal(t, false, *res.Result)
+
+			_, err = h.Rollback(ctx, id, 4)
+			require.NoError(t, err)
+			_, err = c.PolicyGet(ctx, id)
+			assert.True(t, gopa.IsNotFound(err))
+
+			_, err = h.Rollback(ctx, id, 42)
+			assert.True(t, errors.Is(err, gopa.ErrVersionNotFound))
+
+			vs, err = h.History(ctx, "potato")
+			require.NoError(t, err)
+			assert.Empty(t, vs)
+		})
+	}
+}
@@ -0,0 +1,396 @@
+package gopa
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/open-policy-agent/opa/server/types"
+	"github.com/open-policy-agent/opa/storage"
+	"github.com/open-policy-agent/opa/storage/inmem"
+)
+
+// Backend is the backend that answered a request
+type Backend string
+
+// List of the possible Backend
+const (
+	BackendRemote Backend = "remote"
+	BackendLocal  Backend = "local"
+)
+
+// HybridClient is a Service that sends the requests to the remote
+// Service and, when it's unreachable, evaluates the decisions with
+// a local EmbeddedClient that has a copy of the policies and data
+// of the last Sync. The writes are only done if the remote accepts
+// them and then they are also applied to the local copy
+type HybridClient struct {
+	remote        Service
+	dataPaths     []string
+	remoteTimeout time.Duration
+	localDecode   DecodeMode
+
+	// mu guards the local, which is
+	// replaced on each Sync
+	mu    sync.RWMutex
+	local *EmbeddedClient
+}
+
+// HybridOptionFunc is a type used to configure the
+// HybridClient on initialization time
+type HybridOptionFunc func(*HybridClient) error
+
+// SetSyncDataPaths sets the paths of the data that are copied
+// from the remote on each Sync. Only the base documents (the
+// ones pushed as data) should be used, as the virtual
+// documents are computed from the policies
+func SetSyncDataPaths(paths ...string) HybridOptionFunc {
+	return func(h *HybridClient) error {
+		h.dataPaths = paths
+		return nil
+	}
+}
+
+// SetRemoteTimeout sets the max time to wait for the remote
+// on the decisions, so there is still time to evaluate them
+// locally before the deadline of the request
+func SetRemoteTimeout(d time.Duration) HybridOptionFunc {
+	return func(h *HybridClient) error {
+		h.remoteTimeout = d
+		return nil
+	}
+}
+
+// SetLocalDecodeMode sets how the results of the local copy
+// are converted, it should match the DecodeMode of the remote
+func SetLocalDecodeMode(m DecodeMode) HybridOptionFunc {
+	return func(h *HybridClient) error {
+		h.localDecode = m
+		return nil
+	}
+}
+
+// HybridResponse is the DataResponseV1 with
+// the Backend that answered it
+type HybridResponse struct {
+	*types.DataResponseV1
+	Backend Backend
+}
+
+// NewHybridClient initializes a new HybridClient with the
+// remote Service. The local copy is empty until Sync is called
+func NewHybridClient(remote Service, opts ...HybridOptionFunc) (*HybridClient, error) {
+	h := &HybridClient{
+		remote: remote,
+	}
+
+	for _, o := range opts {
+		if err := o(h); err != nil {
+			return nil, err
+		}
+	}
+
+	local, err := NewEmbeddedClient(SetEmbeddedDecodeMode(h.localDecode))
+	if err != nil {
+		return nil, err
+	}
+	h.local = local
+
+	return h, nil
+}
+
+// Sync replaces the local copy with the policies and
+// the data paths of the remote
+func (h *HybridClient) Sync(ctx context.Context) error {
+	pl, err := h.remote.PolicyList(ctx)
+	if err != nil {
+		return err
+	}
+
+	store := inmem.New()
+	err = storage.Txn(ctx, store, storage.WriteParams, func(txn storage.Transaction) error {
+		for _, p := range pl.Result {
+			if err := store.UpsertPolicy(ctx, txn, p.ID, []byte(p.Raw)); err != nil {
+				return err
+			}
+		}
+
+		for _, dp := range h.dataPaths {
+			res, err := h.remote.DataGet(ctx, dp, WithDecodeMode(DecodeUseNumber))
+			if err != nil {
+				return err
+			}
+			if res.Result == nil {
+				continue
+			}
+
+			path, err := dataPath(dp)
+			if err != nil {
+				return err
+			}
+			if len(path) > 0 {
+				if err := storage.MakeDir(ctx, store, txn, path[:len(path)-1]); err != nil {
+					return err
+				}
+			}
+			if err := store.Write(ctx, txn, storage.AddOp, path, *res.Result); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	local, err := NewEmbeddedClient(SetStore(store), SetEmbeddedDecodeMode(h.localDecode))
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.local = local
+	h.mu.Unlock()
+
+	return nil
+}
+
+// Decide evaluates the document on the path with the input, if not
+// nil, and returns which Backend answered it
+func (h *HybridClient) Decide(ctx context.Context, path string, input interface{}, opts ...RequestOptionFunc) (*HybridResponse, error) {
+	// The input may have to be sent twice
+	input, err := bufferReader(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var res *types.DataResponseV1
+	b, err := h.fallback(ctx, func(ctx context.Context, s Service) (err error) {
+		if input == nil {
+			res, err = s.DataGet(ctx, path, opts...)
+		} else {
+			res, err = s.DataGetWithInput(ctx, path, input, opts...)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &HybridResponse{
+		DataResponseV1: res,
+		Backend:        b,
+	}, nil
+}
+
+// fallback calls the f with the remote and, if it was
+// unreachable, with the local. It returns the Backend
+// that answered
+func (h *HybridClient) fallback(ctx context.Context, f func(context.Context, Service) error) (Backend, error) {
+	rctx := ctx
+	if h.remoteTimeout != 0 {
+		var cancel context.CancelFunc
+		rctx, cancel = context.WithTimeout(ctx, h.remoteTimeout)
+		defer cancel()
+	}
+
+	err := f(rctx, h.remote)
+	if err == nil {
+		return BackendRemote, nil
+	}
+
+	if ctx.Err() != nil || !isUnreachable(err) {
+		return BackendRemote, err
+	}
+
+	return BackendLocal, f(ctx, h.getLocal())
+}
+
+// isUnreachable checks if the err means that the remote could
+// not answer, like a network error or a gateway without OPA
+// behind. Any answer of the remote, like an UndefinedError,
+// is not unreachable
+func isUnreachable(err error) bool {
+	var resErr *ResponseError
+	if errors.As(err, &resErr) {
+		switch resErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// getLocal returns the current local copy
+func (h *HybridClient) getLocal() *EmbeddedClient {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	return h.local
+}
+
+// PolicyCreateOrUpdate creates or updates the policy on the remote and
+// then on the local copy. The local errors are ignored as the next
+// Sync will fix them
+func (h *HybridClient) PolicyCreateOrUpdate(ctx context.Context, id string, policy []byte) (*types.PolicyPutResponseV1, error) {
+	res, err := h.remote.PolicyCreateOrUpdate(ctx, id, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	h.getLocal().PolicyCreateOrUpdate(ctx, id, policy)
+
+	return res, nil
+}
+
+// PolicyList returns all the policies of the remote
+func (h *HybridClient) PolicyList(ctx context.Context) (*types.PolicyListResponseV1, error) {
+	return h.remote.PolicyList(ctx)
+}
+
+// PolicyGet returns the policy with the given id from the remote
+func (h *HybridClient) PolicyGet(ctx context.Context, id string) (*types.PolicyGetResponseV1, error) {
+	return h.remote.PolicyGet(ctx, id)
+}
+
+// PolicyDelete deletes the policy on the remote and then on the local copy
+func (h *HybridClient) PolicyDelete(ctx context.Context, id string) (*types.PolicyDeleteResponseV1, error) {
+	res, err := h.remote.PolicyDelete(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	h.getLocal().PolicyDelete(ctx, id)
+
+	return res, nil
+}
+
+// DataCreateOrOverride creates or replaces the data on the remote and then on the local copy
+func (h *HybridClient) DataCreateOrOverride(ctx context.Context, path string, data interface{}) error {
+	data, err := bufferReader(data)
+	if err != nil {
+		return err
+	}
+
+	err = h.remote.DataCreateOrOverride(ctx, path, data)
+	if err != nil {
+		return err
+	}
+
+	h.getLocal().DataCreateOrOverride(ctx, path, data)
+
+	return nil
+}
+
+// DataGet get's the data on the given path p, from the local copy
+// if the remote is unreachable
+func (h *HybridClient) DataGet(ctx context.Context, path string, opts ...RequestOptionFunc) (*types.DataResponseV1, error) {
+	res, err := h.Decide(ctx, path, nil, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.DataResponseV1, nil
+}
+
+// DataGetWithInput get's the data on the given path p with the input i, from
+// the local copy if the remote is unreachable
+func (h *HybridClient) DataGetWithInput(ctx context.Context, path string, input interface{}, opts ...RequestOptionFunc) (*types.DataResponseV1, error) {
+	if input == nil {
+		input = map[string]interface{}{}
+	}
+
+	res, err := h.Decide(ctx, path, input, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.DataResponseV1, nil
+}
+
+// DataUpdate applies the patch on the remote and then on the local copy
+func (h *HybridClient) DataUpdate(ctx context.Context, path string, patch Patch) error {
+	err := h.remote.DataUpdate(ctx, path, patch)
+	if err != nil {
+		return err
+	}
+
+	h.getLocal().DataUpdate(ctx, path, patch)
+
+	return nil
+}
+
+// DataDelete deletes the data on the remote and then on the local copy
+func (h *HybridClient) DataDelete(ctx context.Context, path string) error {
+	err := h.remote.DataDelete(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	h.getLocal().DataDelete(ctx, path)
+
+	return nil
+}
+
+// QuerySimple makes a simple query, on the local copy
+// if the remote is unreachable
+func (h *HybridClient) QuerySimple(ctx context.Context, path string, input interface{}) ([]byte, error) {
+	input, err := bufferReader(input)
+	if err != nil {
+		return nil, err
+	}
+
+	var res []byte
+	_, err = h.fallback(ctx, func(ctx context.Context, s Service) (err error) {
+		res, err = s.QuerySimple(ctx, path, input)
+		return err
+	})
+
+	return res, err
+}
+
+// QueryAdHoc makes a AdHoc query, on the local copy
+// if the remote is unreachable
+func (h *HybridClient) QueryAdHoc(ctx context.Context, path string, opt QueryAdHocOptions) (*types.QueryResponseV1, error) {
+	var res *types.QueryResponseV1
+	_, err := h.fallback(ctx, func(ctx context.Context, s Service) (err error) {
+		res, err = s.QueryAdHoc(ctx, path, opt)
+		return err
+	})
+
+	return res, err
+}
+
+// CompilePartial partially evaluates the query, on the
+// local copy if the remote is unreachable
+func (h *HybridClient) CompilePartial(ctx context.Context, opt CompileOptions) (*CompileResponse, error) {
+	var res *CompileResponse
+	_, err := h.fallback(ctx, func(ctx context.Context, s Service) (err error) {
+		res, err = s.CompilePartial(ctx, opt)
+		return err
+	})
+
+	return res, err
+}
+
+// Health checks if the remote is healthy
+func (h *HybridClient) Health(ctx context.Context, opt HealthOptions) (*HealthResponse, error) {
+	return h.remote.Health(ctx, opt)
+}
+
+// ConfigGet returns the configuration of the remote
+func (h *HybridClient) ConfigGet(ctx context.Context) (*ConfigResponse, error) {
+	return h.remote.ConfigGet(ctx)
+}
+
+// StatusGet returns the status of the remote
+func (h *HybridClient) StatusGet(ctx context.Context) (*StatusResponse, error) {
+	return h.remote.StatusGet(ctx)
+}
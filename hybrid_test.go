@@ -0,0 +1,130 @@
+package gopa_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHybridClient(t *testing.T) {
+	// The remote goes through a proxy that can
+	// simulate that OPA is down
+	var down int32
+	u, _ := url.Parse(gopa.DefaultURL)
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&down) == 1 {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		proxy.ServeHTTP(w, r)
+	}))
+	defer ts.Close()
+
+	remote, err := gopa.NewClient(gopa.SetURL(ts.URL))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	policyID := "example-hybrid"
+	policy := []byte(`
+package opa.examples
+
+allow_request { data.hybrid.users[input.user].admin }
+`)
+	dataBody := map[string]interface{}{
+		"alice": map[string]interface{}{"admin": true},
+		"bob":   map[string]interface{}{"admin": false},
+	}
+
+	_, err = remote.PolicyCreateOrUpdate(ctx, policyID, policy)
+	require.NoError(t, err)
+	defer remote.PolicyDelete(ctx, policyID)
+
+	err = remote.DataCreateOrOverride(ctx, "hybrid/users", dataBody)
+	require.NoError(t, err)
+	defer remote.DataDelete(ctx, "hybrid")
+
+	h, err := gopa.NewHybridClient(remote, gopa.SetSyncDataPaths("hybrid/users"))
+	require.NoError(t, err)
+
+	assert.Implements(t, (*gopa.Service)(nil), h)
+
+	err = h.Sync(ctx)
+	require.NoError(t, err)
+
+	t.Run("Remote", func(t *testing.T) {
+		res, err := h.Decide(ctx, "opa/examples/allow_request", map[string]interface{}{"user": "alice"})
+		require.NoError(t, err)
+		assert.Equal(t, gopa.BackendRemote, res.Backend)
+		assert.Equal(t, true, *res.Result)
+	})
+
+	t.Run("Write", func(t *testing.T) {
+		err := h.DataUpdate(ctx, "hybrid/users", gopa.Patch{}.Add(gopa.PatchPath("carol"), map[string]interface{}{"admin": true}))
+		require.NoError(t, err)
+	})
+
+	t.Run("Local", func(t *testing.T) {
+		atomic.StoreInt32(&down, 1)
+		defer atomic.StoreInt32(&down, 0)
+
+		res, err := h.Decide(ctx, "opa/examples/allow_request", map[string]interface{}{"user": "alice"})
+		require.NoError(t, err)
+		assert.Equal(t, gopa.BackendLocal, res.Backend)
+		assert.Equal(t, true, *res.Result)
+
+		res, err = h.Decide(ctx, "opa/examples/allow_request", map[string]interface{}{"user": "carol"})
+		require.NoError(t, err)
+		assert.Equal(t, gopa.BackendLocal, res.Backend)
+		assert.Equal(t, true, *res.Result, "The writes are applied to the local copy")
+
+		res, err = h.Decide(ctx, "opa/examples/allow_request", map[string]interface{}{"user": "bob"})
+		require.NoError(t, err)
+		assert.Equal(t, gopa.BackendLocal, res.Backend)
+		assert.Nil(t, res.Result)
+
+		dres, err := h.DataGet(ctx, "hybrid/users/alice/admin")
+		require.NoError(t, err)
+		assert.Equal(t, true, *dres.Result)
+
+		err = h.DataDelete(ctx, "hybrid/users/alice")
+		assert.Error(t, err, "The writes are not done if the remote is unreachable")
+
+		err = h.Sync(ctx)
+		assert.Error(t, err)
+	})
+
+	t.Run("RemoteUndefined", func(t *testing.T) {
+		// The local copy still has alice as admin
+		err := remote.DataCreateOrOverride(ctx, "hybrid/users/alice", map[string]interface{}{"admin": false})
+		require.NoError(t, err)
+
+		res, err := h.Decide(ctx, "opa/examples/allow_request", map[string]interface{}{"user": "alice"})
+		require.NoError(t, err)
+		assert.Equal(t, gopa.BackendRemote, res.Backend)
+		assert.Nil(t, res.Result)
+
+		_, err = h.Decide(ctx, "opa/examples/allow_request", map[string]interface{}{"user": "alice"}, gopa.WithStrictUndefined())
+		assert.True(t, gopa.IsUndefined(err), "The undefined of the remote is an answer")
+
+		a := gopa.Authorize(ctx, h, "opa/examples/allow_request", map[string]interface{}{"user": "alice"})
+		assert.False(t, a.Allowed)
+		assert.True(t, gopa.IsUndefined(a.Err))
+	})
+
+	t.Run("RemoteError", func(t *testing.T) {
+		_, err := h.CompilePartial(ctx, gopa.CompileOptions{Query: "x := "})
+		require.Error(t, err)
+		assert.True(t, gopa.IsInvalidParameter(err), "The errors of the remote are returned")
+	})
+}
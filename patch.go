@@ -0,0 +1,53 @@
+package gopa
+
+import (
+	"strings"
+
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// Patch operations supported by OPA
+const (
+	PatchOpAdd     = "add"
+	PatchOpRemove  = "remove"
+	PatchOpReplace = "replace"
+)
+
+// Patch is a list of JSON Patch (RFC 6902) operations to
+// apply to a document. The paths are JSON Pointers (RFC 6901)
+// relative to the document being patched and can be built
+// with PatchPath
+type Patch []types.PatchV1
+
+// Add adds the value v on the path p
+func (p Patch) Add(path string, v interface{}) Patch {
+	return append(p, types.PatchV1{Op: PatchOpAdd, Path: path, Value: v})
+}
+
+// Remove removes the value on the path p
+func (p Patch) Remove(path string) Patch {
+	return append(p, types.PatchV1{Op: PatchOpRemove, Path: path})
+}
+
+// Replace replaces the value on the path p with v
+func (p Patch) Replace(path string, v interface{}) Patch {
+	return append(p, types.PatchV1{Op: PatchOpReplace, Path: path, Value: v})
+}
+
+// pointerEscaper escapes the JSON Pointer reserved characters
+var pointerEscaper = strings.NewReplacer("~", "~0", "/", "~1")
+
+// PatchPath builds a JSON Pointer from the segments escaping them,
+// so PatchPath("servers", "a/b") returns "/servers/a~1b". Use "-"
+// as the last segment to append to an array
+func PatchPath(segments ...string) string {
+	var sb strings.Builder
+	for _, s := range segments {
+		sb.WriteString("/")
+		sb.WriteString(pointerEscaper.Replace(s))
+	}
+	if sb.Len() == 0 {
+		return "/"
+	}
+	return sb.String()
+}
@@ -0,0 +1,30 @@
+package gopa_test
+
+import (
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/open-policy-agent/opa/server/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPatch(t *testing.T) {
+	t.Run("Builder", func(t *testing.T) {
+		p := gopa.Patch{}.
+			Add("/a", 1).
+			Remove("/b").
+			Replace("/c", "d")
+
+		assert.Equal(t, gopa.Patch{
+			types.PatchV1{Op: "add", Path: "/a", Value: 1},
+			types.PatchV1{Op: "remove", Path: "/b"},
+			types.PatchV1{Op: "replace", Path: "/c", Value: "d"},
+		}, p)
+	})
+
+	t.Run("PatchPath", func(t *testing.T) {
+		assert.Equal(t, "/", gopa.PatchPath())
+		assert.Equal(t, "/servers/0/name", gopa.PatchPath("servers", "0", "name"))
+		assert.Equal(t, "/a~1b/c~0d/-", gopa.PatchPath("a/b", "c~d", "-"))
+	})
+}
@@ -2,6 +2,7 @@ package gopa
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"path"
 
@@ -23,13 +24,20 @@ func NewPolicyService(c *Client) *PolicyService {
 	}
 }
 
-// CreateOrUpdate creates or updates the policy with the give id and the content policy
+// CreateOrUpdate creates or updates the policy with the give id and the content policy.
+// If the policy does not compile the returned APIError has the lines that failed
 // https://www.openpolicyagent.org/docs/latest/rest-api/#create-or-update-a-policy
 func (ps *PolicyService) CreateOrUpdate(ctx context.Context, id string, policy []byte) (*types.PolicyPutResponseV1, error) {
 	var res types.PolicyPutResponseV1
 
 	err := ps.client.do(ctx, http.MethodPut, path.Join(ps.path, id), policy, &res)
 	if err != nil {
+		// OPA uses the id as the file of the errors so we can
+		// add the lines of the policy that failed
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			apiErr.setSource(id, policy)
+		}
 		return nil, err
 	}
 
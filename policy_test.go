@@ -2,6 +2,7 @@ package gopa_test
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/cycloidio/gopa"
@@ -63,6 +64,35 @@ potato
 			_, err = c.PolicyCreateOrUpdate(ctx, policyID, policy)
 			assert.Contains(t, err.Error(), types.CodeInvalidParameter)
 		})
+
+		t.Run("CompileErrors", func(t *testing.T) {
+			c, err := gopa.NewClient()
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			policy := []byte("package opa.examples\n\ndeny {\n\tfoo(1)\n\tbar(2)\n}\n")
+
+			_, err = c.PolicyCreateOrUpdate(ctx, policyID, policy)
+			require.Error(t, err)
+
+			var apiErr *gopa.APIError
+			require.True(t, errors.As(err, &apiErr))
+			require.Len(t, apiErr.Errors, 2)
+			assert.Equal(t, gopa.Location{
+				Text:   []byte("\tfoo(1)"),
+				File:   policyID,
+				Row:    4,
+				Col:    2,
+				Offset: 30,
+			}, apiErr.Errors[0].Location)
+			assert.Equal(t, `invalid_parameter: error(s) occurred while compiling module(s)
+example1:4:2: rego_type_error: undefined function foo
+	`+"\tfoo(1)"+`
+	`+"\t^"+`
+example1:5:2: rego_type_error: undefined function bar
+	`+"\tbar(2)"+`
+	`+"\t^", apiErr.Error())
+		})
 	})
 
 	t.Run("List", func(t *testing.T) {
@@ -25,26 +25,23 @@ func NewQueryService(c *Client) *QueryService {
 	}
 }
 
-// Simple makes a simple query to the path p with the give input
+// Simple makes a simple query to the path p with the give input, which
+// can be any value that can be encoded to JSON, a json.RawMessage or an
+// io.Reader with the JSON
 // https://www.openpolicyagent.org/docs/latest/rest-api/#execute-a-simple-query
-func (qs *QueryService) Simple(ctx context.Context, p string, input map[string]interface{}) ([]byte, error) {
-	b, err := json.Marshal(input)
+func (qs *QueryService) Simple(ctx context.Context, p string, input interface{}) ([]byte, error) {
+	body, err := jsonBody(input)
 	if err != nil {
 		return nil, err
 	}
 
-	req, err := qs.client.request(ctx, http.MethodPost, p, b)
-	if err != nil {
-		return nil, err
-	}
-
-	res, err := qs.client.client.Do(req)
+	res, err := qs.client.send(ctx, http.MethodPost, p, nil, body)
 	if err != nil {
 		return nil, err
 	}
 	defer res.Body.Close()
 
-	b, err = ioutil.ReadAll(res.Body)
+	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -54,9 +51,10 @@ func (qs *QueryService) Simple(ctx context.Context, p string, input map[string]i
 
 // QueryAdHocOptions are the options available to the AdHoc
 type QueryAdHocOptions struct {
-	Query    string                 `json:"query,omitempty"`
-	Input    map[string]interface{} `json:"input,omitempty"`
-	Unknowns []string               `json:"unknowns,omitempty"`
+	Query string `json:"query,omitempty"`
+	// Input can be any value that can be encoded to JSON
+	Input    interface{} `json:"input,omitempty"`
+	Unknowns []string    `json:"unknowns,omitempty"`
 }
 
 // AdHoc makes a AdHoc query to the path p with the give opt
@@ -0,0 +1,118 @@
+package gopa
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// RetryPolicy configures how the failed requests are retried.
+// Only the connection errors and the RetryableStatusCodes are
+// retried and never for PATCH requests, as all the other
+// methods of the OPA API are idempotent
+type RetryPolicy struct {
+	// MaxAttempts is the max number of attempts
+	// including the first one
+	MaxAttempts int
+	// MinBackoff is the wait before the first retry, it's
+	// doubled on each attempt. With 0 the retries are not delayed
+	MinBackoff time.Duration
+	// MaxBackoff is the max wait between attempts,
+	// with 0 the backoff is not capped
+	MaxBackoff time.Duration
+	// Jitter is the fraction (from 0 to 1) of the backoff that
+	// is randomly removed to avoid all the clients retrying at
+	// the same time
+	Jitter float64
+	// RetryableStatusCodes are the response status that are retried
+	RetryableStatusCodes []int
+}
+
+// DefaultRetryPolicy is a RetryPolicy that retries the
+// errors that happen while OPA is restarting
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 3,
+	MinBackoff:  100 * time.Millisecond,
+	MaxBackoff:  2 * time.Second,
+	Jitter:      0.2,
+	RetryableStatusCodes: []int{
+		http.StatusBadGateway,
+		http.StatusServiceUnavailable,
+		http.StatusGatewayTimeout,
+	},
+}
+
+// SetRetryPolicy sets the rp as the policy to retry
+// the failed requests, by default they are not retried
+func SetRetryPolicy(rp RetryPolicy) ClientOptionFunc {
+	return func(c *Client) error {
+		if rp.MaxAttempts < 1 {
+			return errors.New("the MaxAttempts of the RetryPolicy has to be at least 1")
+		}
+		if rp.Jitter < 0 || rp.Jitter > 1 {
+			return errors.New("the Jitter of the RetryPolicy has to be between 0 and 1")
+		}
+		c.retry = &rp
+		return nil
+	}
+}
+
+// shouldRetry checks if the request with the method that got the
+// res or err on the attempt (starting at 1) has to be retried
+func (rp *RetryPolicy) shouldRetry(ctx context.Context, method string, attempt int, res *http.Response, err error) bool {
+	if rp == nil || attempt >= rp.MaxAttempts || method == http.MethodPatch || ctx.Err() != nil {
+		return false
+	}
+
+	if err != nil {
+		return true
+	}
+
+	for _, sc := range rp.RetryableStatusCodes {
+		if res.StatusCode == sc {
+			return true
+		}
+	}
+
+	return false
+}
+
+// backoff returns the time to wait after the attempt
+func (rp *RetryPolicy) backoff(attempt int) time.Duration {
+	b := rp.MinBackoff
+	for i := 1; i < attempt && (rp.MaxBackoff <= 0 || b < rp.MaxBackoff); i++ {
+		// Without cap it stops before overflowing
+		if b > math.MaxInt64/2 {
+			break
+		}
+		b *= 2
+	}
+	if rp.MaxBackoff > 0 && b > rp.MaxBackoff {
+		b = rp.MaxBackoff
+	}
+
+	return b - time.Duration(rp.Jitter*rand.Float64()*float64(b))
+}
+
+// wait waits the backoff of the attempt, it returns false if the
+// ctx is done or its deadline would be exceeded while waiting
+func (rp *RetryPolicy) wait(ctx context.Context, attempt int) bool {
+	b := rp.backoff(attempt)
+
+	if d, ok := ctx.Deadline(); ok && time.Until(d) < b {
+		return false
+	}
+
+	t := time.NewTimer(b)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
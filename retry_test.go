@@ -0,0 +1,222 @@
+package gopa_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	rp := gopa.RetryPolicy{
+		MaxAttempts:          3,
+		MinBackoff:           time.Millisecond,
+		MaxBackoff:           5 * time.Millisecond,
+		Jitter:               0.5,
+		RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+	}
+
+	// newServer returns a server that fails with the status
+	// sc until the fails are done and the number of requests
+	newServer := func(sc int, fails int32) (*httptest.Server, *int32) {
+		var count int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			b, _ := ioutil.ReadAll(r.Body)
+			assert.Equal(t, `{"potato":"yes"}`, string(b), "The body is sent on each attempt")
+			if atomic.AddInt32(&count, 1) <= fails {
+				w.WriteHeader(sc)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		return ts, &count
+	}
+
+	body := map[string]interface{}{"potato": "yes"}
+
+	t.Run("Success", func(t *testing.T) {
+		ts, count := newServer(http.StatusServiceUnavailable, 2)
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL), gopa.SetRetryPolicy(rp))
+		require.NoError(t, err)
+
+		err = c.DataCreateOrOverride(context.Background(), "potato", body)
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(count))
+	})
+
+	t.Run("MaxAttempts", func(t *testing.T) {
+		ts, count := newServer(http.StatusServiceUnavailable, 5)
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL), gopa.SetRetryPolicy(rp))
+		require.NoError(t, err)
+
+		err = c.DataCreateOrOverride(context.Background(), "potato", body)
+		require.Error(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(count))
+	})
+
+	t.Run("NotRetryableStatus", func(t *testing.T) {
+		ts, count := newServer(http.StatusBadRequest, 1)
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL), gopa.SetRetryPolicy(rp))
+		require.NoError(t, err)
+
+		err = c.DataCreateOrOverride(context.Background(), "potato", body)
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(count))
+	})
+
+	t.Run("NoRetryPolicy", func(t *testing.T) {
+		ts, count := newServer(http.StatusServiceUnavailable, 1)
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL))
+		require.NoError(t, err)
+
+		err = c.DataCreateOrOverride(context.Background(), "potato", body)
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(count))
+	})
+
+	t.Run("Patch", func(t *testing.T) {
+		var count int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&count, 1)
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}))
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL), gopa.SetRetryPolicy(rp))
+		require.NoError(t, err)
+
+		err = c.DataUpdate(context.Background(), "potato", gopa.Patch{}.Remove("/a"))
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(&count))
+	})
+
+	t.Run("Reader", func(t *testing.T) {
+		ts, count := newServer(http.StatusServiceUnavailable, 2)
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL), gopa.SetRetryPolicy(rp))
+		require.NoError(t, err)
+
+		// The readers that can't be sent again are not retried
+		err = c.DataCreateOrOverride(context.Background(), "potato", ioutil.NopCloser(strings.NewReader(`{"potato":"yes"}`)))
+		require.Error(t, err)
+		assert.Equal(t, int32(1), atomic.LoadInt32(count))
+
+		err = c.DataCreateOrOverride(context.Background(), "potato", strings.NewReader(`{"potato":"yes"}`))
+		require.NoError(t, err)
+		assert.Equal(t, int32(3), atomic.LoadInt32(count))
+	})
+
+	t.Run("ConnectionError", func(t *testing.T) {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL), gopa.SetRetryPolicy(rp))
+		require.NoError(t, err)
+
+		err = c.DataCreateOrOverride(context.Background(), "potato", body)
+		require.Error(t, err)
+	})
+
+	t.Run("Deadline", func(t *testing.T) {
+		ts, count := newServer(http.StatusServiceUnavailable, 5)
+		defer ts.Close()
+
+		lrp := rp
+		lrp.MinBackoff = time.Minute
+		lrp.MaxBackoff = time.Minute
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL), gopa.SetRetryPolicy(lrp))
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+
+		start := time.Now()
+		err = c.DataCreateOrOverride(ctx, "potato", body)
+		require.Error(t, err)
+		assert.True(t, time.Since(start) < time.Second, "It does not wait for a backoff longer than the deadline")
+		assert.Equal(t, int32(1), atomic.LoadInt32(count))
+	})
+
+	t.Run("Backoff", func(t *testing.T) {
+		// gaps returns the time between the attempts
+		// of a request that always fails
+		gaps := func(t *testing.T, rp gopa.RetryPolicy) []time.Duration {
+			var (
+				mu    sync.Mutex
+				times []time.Time
+			)
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				times = append(times, time.Now())
+				mu.Unlock()
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}))
+			defer ts.Close()
+
+			c, err := gopa.NewClient(gopa.SetURL(ts.URL), gopa.SetRetryPolicy(rp))
+			require.NoError(t, err)
+
+			err = c.DataCreateOrOverride(context.Background(), "potato", body)
+			require.Error(t, err)
+
+			mu.Lock()
+			defer mu.Unlock()
+			require.Len(t, times, rp.MaxAttempts)
+			gaps := make([]time.Duration, 0, len(times)-1)
+			for i := 1; i < len(times); i++ {
+				gaps = append(gaps, times[i].Sub(times[i-1]))
+			}
+			return gaps
+		}
+
+		t.Run("Capped", func(t *testing.T) {
+			g := gaps(t, gopa.RetryPolicy{
+				MaxAttempts:          5,
+				MinBackoff:           20 * time.Millisecond,
+				MaxBackoff:           50 * time.Millisecond,
+				RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+			})
+			for i, min := range []time.Duration{20, 40, 50, 50} {
+				assert.True(t, g[i] >= min*time.Millisecond, "attempt %d waited %s", i+2, g[i])
+			}
+			assert.True(t, g[3] < 90*time.Millisecond, "The backoff is capped but waited %s", g[3])
+		})
+
+		t.Run("NotCapped", func(t *testing.T) {
+			g := gaps(t, gopa.RetryPolicy{
+				MaxAttempts:          4,
+				MinBackoff:           20 * time.Millisecond,
+				RetryableStatusCodes: []int{http.StatusServiceUnavailable},
+			})
+			for i, min := range []time.Duration{20, 40, 80} {
+				assert.True(t, g[i] >= min*time.Millisecond, "attempt %d waited %s", i+2, g[i])
+			}
+		})
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := gopa.NewClient(gopa.SetRetryPolicy(gopa.RetryPolicy{}))
+		assert.Error(t, err)
+
+		_, err = gopa.NewClient(gopa.SetRetryPolicy(gopa.RetryPolicy{MaxAttempts: 2, Jitter: 2}))
+		assert.Error(t, err)
+	})
+}
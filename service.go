@@ -13,14 +13,22 @@ type Service interface {
 	PolicyGet(ctx context.Context, id string) (*types.PolicyGetResponseV1, error)
 	PolicyDelete(ctx context.Context, id string) (*types.PolicyDeleteResponseV1, error)
 
-	DataCreateOrOverride(ctx context.Context, path string, data map[string]interface{}) error
-	DataGet(ctx context.Context, path string) (*types.DataResponseV1, error)
-	DataGetWithInput(ctx context.Context, path string, input map[string]interface{}) (*types.DataResponseV1, error)
-	DataUpdate(ctx context.Context, path string, data map[string]interface{}) error
+	DataCreateOrOverride(ctx context.Context, path string, data interface{}) error
+	DataGet(ctx context.Context, path string, opts ...RequestOptionFunc) (*types.DataResponseV1, error)
+	DataGetWithInput(ctx context.Context, path string, input interface{}, opts ...RequestOptionFunc) (*types.DataResponseV1, error)
+	DataUpdate(ctx context.Context, path string, patch Patch) error
 	DataDelete(ctx context.Context, path string) error
 
-	QuerySimple(ctx context.Context, path string, input map[string]interface{}) ([]byte, error)
+	QuerySimple(ctx context.Context, path string, input interface{}) ([]byte, error)
 	QueryAdHoc(ctx context.Context, path string, opt QueryAdHocOptions) (*types.QueryResponseV1, error)
+
+	CompilePartial(ctx context.Context, opt CompileOptions) (*CompileResponse, error)
+
+	Health(ctx context.Context, opt HealthOptions) (*HealthResponse, error)
+
+	ConfigGet(ctx context.Context) (*ConfigResponse, error)
+
+	StatusGet(ctx context.Context) (*StatusResponse, error)
 }
 
 // PolicyCreateOrUpdate creates or updates the policy with the give id and the content policy
@@ -49,27 +57,26 @@ func (c *Client) PolicyDelete(ctx context.Context, id string) (*types.PolicyDele
 
 // DataCreateOrOverride creates or replaces the given data on the path p
 // https://www.openpolicyagent.org/docs/latest/rest-api/#create-or-overwrite-a-document
-func (c *Client) DataCreateOrOverride(ctx context.Context, path string, data map[string]interface{}) error {
+func (c *Client) DataCreateOrOverride(ctx context.Context, path string, data interface{}) error {
 	return c.datasvc.CreateOrOverride(ctx, path, data)
 }
 
 // DataGet get's the data on the given path p
 // https://www.openpolicyagent.org/docs/latest/rest-api/#get-a-document
-func (c *Client) DataGet(ctx context.Context, path string) (*types.DataResponseV1, error) {
-	return c.datasvc.Get(ctx, path)
+func (c *Client) DataGet(ctx context.Context, path string, opts ...RequestOptionFunc) (*types.DataResponseV1, error) {
+	return c.datasvc.Get(ctx, path, opts...)
 }
 
 // DataGetWithInput get's the data on the given path p with the input i
 // https://www.openpolicyagent.org/docs/latest/rest-api/#get-a-document-with-input
-func (c *Client) DataGetWithInput(ctx context.Context, path string, input map[string]interface{}) (*types.DataResponseV1, error) {
-	return c.datasvc.GetWithInput(ctx, path, input)
+func (c *Client) DataGetWithInput(ctx context.Context, path string, input interface{}, opts ...RequestOptionFunc) (*types.DataResponseV1, error) {
+	return c.datasvc.GetWithInput(ctx, path, input, opts...)
 }
 
-// DataUpdate updates the data on the given path p. Can be used to do partial updates
-// by using the path to specify the element
+// DataUpdate applies the patch to the data on the given path p
 // https://www.openpolicyagent.org/docs/latest/rest-api/#patch-a-document
-func (c *Client) DataUpdate(ctx context.Context, path string, data map[string]interface{}) error {
-	return c.datasvc.Update(ctx, path, data)
+func (c *Client) DataUpdate(ctx context.Context, path string, patch Patch) error {
+	return c.datasvc.Update(ctx, path, patch)
 }
 
 // DataDelete deletes the data on the given path p
@@ -80,7 +87,7 @@ func (c *Client) DataDelete(ctx context.Context, path string) error {
 
 // QuerySimple makes a simple query to the path p with the give input
 // https://www.openpolicyagent.org/docs/latest/rest-api/#execute-a-simple-query
-func (c *Client) QuerySimple(ctx context.Context, path string, input map[string]interface{}) ([]byte, error) {
+func (c *Client) QuerySimple(ctx context.Context, path string, input interface{}) ([]byte, error) {
 	return c.querysvc.Simple(ctx, path, input)
 }
 
@@ -89,3 +96,28 @@ func (c *Client) QuerySimple(ctx context.Context, path string, input map[string]
 func (c *Client) QueryAdHoc(ctx context.Context, path string, opt QueryAdHocOptions) (*types.QueryResponseV1, error) {
 	return c.querysvc.AdHoc(ctx, path, opt)
 }
+
+// CompilePartial partially evaluates the query with the given opt
+// https://www.openpolicyagent.org/docs/latest/rest-api/#partially-evaluate-a-query
+func (c *Client) CompilePartial(ctx context.Context, opt CompileOptions) (*CompileResponse, error) {
+	return c.compilesvc.Partial(ctx, opt)
+}
+
+// Health checks if OPA is healthy with the given opt, with
+// SetEndpoints only the first one is checked
+// https://www.openpolicyagent.org/docs/latest/rest-api/#health-api
+func (c *Client) Health(ctx context.Context, opt HealthOptions) (*HealthResponse, error) {
+	return c.healthsvc.Check(ctx, opt)
+}
+
+// ConfigGet returns the active configuration of OPA
+// https://www.openpolicyagent.org/docs/latest/rest-api/#config-api
+func (c *Client) ConfigGet(ctx context.Context) (*ConfigResponse, error) {
+	return c.configsvc.Get(ctx)
+}
+
+// StatusGet returns the status of OPA
+// https://www.openpolicyagent.org/docs/latest/rest-api/#status-api
+func (c *Client) StatusGet(ctx context.Context) (*StatusResponse, error) {
+	return c.statussvc.Get(ctx)
+}
@@ -0,0 +1,314 @@
+// Package sqlfilter translates the residual queries returned by the
+// Compile API into parameterised SQL WHERE clauses so the rows can be
+// filtered by the policy directly on the database
+package sqlfilter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// Clauses used when the result of the partial evaluation
+// does not depend on the unknowns
+const (
+	ClauseTrue  = "1 = 1"
+	ClauseFalse = "1 = 0"
+)
+
+// operators maps the Rego builtins to the SQL operators
+var operators = map[string]string{
+	ast.Equality.Name:      "=",
+	ast.Equal.Name:         "=",
+	ast.NotEqual.Name:      "<>",
+	ast.LessThan.Name:      "<",
+	ast.LessThanEq.Name:    "<=",
+	ast.GreaterThan.Name:   ">",
+	ast.GreaterThanEq.Name: ">=",
+}
+
+// swapped are the operators to use when the
+// column is on the right side of the comparison
+var swapped = map[string]string{
+	"=":  "=",
+	"<>": "<>",
+	"<":  ">",
+	"<=": ">=",
+	">":  "<",
+	">=": "<=",
+}
+
+// ColumnFunc returns the SQL column for the path of fields
+// found under the unknown. For 'data.resources[_].owner.id'
+// with the unknown 'data.resources' it's called with
+// ("data.resources", ["owner", "id"])
+type ColumnFunc func(unknown string, path []string) (string, error)
+
+// PlaceholderFunc returns the placeholder of the n (starting at 1) argument
+type PlaceholderFunc func(n int) string
+
+// Translator translates the residual queries into SQL
+type Translator struct {
+	unknowns    []ast.Ref
+	columns     ColumnFunc
+	placeholder PlaceholderFunc
+}
+
+// OptionFunc is a type used to configure the Translator
+// on initialization time
+type OptionFunc func(*Translator) error
+
+// SetColumnFunc sets the cf as the mapping between
+// the unknowns and the SQL columns
+func SetColumnFunc(cf ColumnFunc) OptionFunc {
+	return func(t *Translator) error {
+		t.columns = cf
+		return nil
+	}
+}
+
+// SetPlaceholderFunc sets the pf as the placeholder
+// used for the arguments
+func SetPlaceholderFunc(pf PlaceholderFunc) OptionFunc {
+	return func(t *Translator) error {
+		t.placeholder = pf
+		return nil
+	}
+}
+
+// DefaultColumn uses the last element of the unknown as the table
+// and joins the path with '_' as the column, so 'data.resources[_].owner.id'
+// is translated to 'resources.owner_id'
+func DefaultColumn(unknown string, path []string) (string, error) {
+	parts := strings.Split(unknown, ".")
+	return fmt.Sprintf("%s.%s", parts[len(parts)-1], strings.Join(path, "_")), nil
+}
+
+// QuestionPlaceholder uses '?' as placeholder, like MySQL and SQLite
+func QuestionPlaceholder(n int) string {
+	return "?"
+}
+
+// DollarPlaceholder uses '$n' as placeholder, like PostgreSQL
+func DollarPlaceholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+// New initializes a new Translator for the given unknowns,
+// which have to be the same ones used on the Compile query
+func New(unknowns []string, opts ...OptionFunc) (*Translator, error) {
+	t := &Translator{
+		columns:     DefaultColumn,
+		placeholder: QuestionPlaceholder,
+	}
+
+	for _, u := range unknowns {
+		r, err := ast.ParseRef(u)
+		if err != nil {
+			return nil, fmt.Errorf("invalid unknown %q: %w", u, err)
+		}
+		t.unknowns = append(t.unknowns, r)
+	}
+
+	for _, o := range opts {
+		if err := o(t); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// Translate translates the result of a partial evaluation into a
+// SQL predicate and the arguments for its placeholders. Each query
+// is joined with OR and each expression of a query with AND.
+// An undefined result (no queries) is translated to ClauseFalse
+func (t *Translator) Translate(r *types.PartialEvaluationResultV1) (string, []interface{}, error) {
+	if r == nil || len(r.Queries) == 0 {
+		return ClauseFalse, nil, nil
+	}
+
+	if len(r.Support) != 0 {
+		return "", nil, fmt.Errorf("support modules can not be translated to SQL")
+	}
+
+	var (
+		args    []interface{}
+		clauses []string
+	)
+
+	for _, q := range r.Queries {
+		if len(q) == 0 {
+			// An empty query is always true so
+			// the rest do not matter
+			return ClauseTrue, nil, nil
+		}
+
+		exprs := make([]string, 0, len(q))
+		for _, e := range q {
+			s, err := t.translateExpr(e, &args)
+			if err != nil {
+				return "", nil, err
+			}
+			exprs = append(exprs, s)
+		}
+
+		if len(exprs) == 1 {
+			clauses = append(clauses, exprs[0])
+		} else {
+			clauses = append(clauses, fmt.Sprintf("(%s)", strings.Join(exprs, " AND ")))
+		}
+	}
+
+	if len(clauses) == 1 {
+		return clauses[0], args, nil
+	}
+
+	return fmt.Sprintf("(%s)", strings.Join(clauses, " OR ")), args, nil
+}
+
+// translateExpr translates the expression e and appends
+// the needed arguments to args
+func (t *Translator) translateExpr(e *ast.Expr, args *[]interface{}) (string, error) {
+	if len(e.With) != 0 {
+		return "", fmt.Errorf("unsupported expression %q: 'with' can not be translated", e)
+	}
+
+	var (
+		s   string
+		err error
+	)
+
+	switch terms := e.Terms.(type) {
+	case *ast.Term:
+		// A single reference is a boolean column
+		var col string
+		col, err = t.column(terms)
+		if err != nil {
+			return "", fmt.Errorf("unsupported expression %q: %w", e, err)
+		}
+		s = fmt.Sprintf("%s = %s", col, t.arg(true, args))
+	case []*ast.Term:
+		s, err = t.translateCall(e, terms, args)
+		if err != nil {
+			return "", err
+		}
+	default:
+		return "", fmt.Errorf("unsupported expression %q", e)
+	}
+
+	if e.Negated {
+		s = fmt.Sprintf("NOT (%s)", s)
+	}
+
+	return s, nil
+}
+
+// translateCall translates the call expression e with the terms
+// into a comparison between a column and a value or another column
+func (t *Translator) translateCall(e *ast.Expr, terms []*ast.Term, args *[]interface{}) (string, error) {
+	if len(terms) != 3 {
+		return "", fmt.Errorf("unsupported expression %q", e)
+	}
+
+	op, ok := operators[e.Operator().String()]
+	if !ok {
+		return "", fmt.Errorf("unsupported expression %q: operator %q can not be translated", e, e.Operator())
+	}
+
+	left, right := terms[1], terms[2]
+	lcol, lerr := t.column(left)
+	rcol, rerr := t.column(right)
+
+	switch {
+	case lerr == nil && rerr == nil:
+		return fmt.Sprintf("%s %s %s", lcol, op, rcol), nil
+	case lerr == nil:
+		return t.comparison(e, lcol, op, right, args)
+	case rerr == nil:
+		return t.comparison(e, rcol, swapped[op], left, args)
+	default:
+		return "", fmt.Errorf("unsupported expression %q: %w", e, lerr)
+	}
+}
+
+// comparison builds the comparison of the col with the value v
+func (t *Translator) comparison(e *ast.Expr, col, op string, v *ast.Term, args *[]interface{}) (string, error) {
+	value, err := ast.JSON(v.Value)
+	if err != nil {
+		return "", fmt.Errorf("unsupported expression %q: %w", e, err)
+	}
+
+	switch value := value.(type) {
+	case nil:
+		switch op {
+		case "=":
+			return fmt.Sprintf("%s IS NULL", col), nil
+		case "<>":
+			return fmt.Sprintf("%s IS NOT NULL", col), nil
+		}
+		return "", fmt.Errorf("unsupported expression %q: null can only be compared by equality", e)
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return fmt.Sprintf("%s %s %s", col, op, t.arg(i, args)), nil
+		}
+		f, err := value.Float64()
+		if err != nil {
+			return "", fmt.Errorf("unsupported expression %q: %w", e, err)
+		}
+		return fmt.Sprintf("%s %s %s", col, op, t.arg(f, args)), nil
+	case string, bool:
+		return fmt.Sprintf("%s %s %s", col, op, t.arg(value, args)), nil
+	}
+
+	return "", fmt.Errorf("unsupported expression %q: only scalar values can be translated", e)
+}
+
+// column returns the column of the term if it's
+// a reference to one of the unknowns
+func (t *Translator) column(term *ast.Term) (string, error) {
+	ref, ok := term.Value.(ast.Ref)
+	if !ok {
+		return "", fmt.Errorf("%q is not a reference", term)
+	}
+
+	for _, u := range t.unknowns {
+		if !ref.HasPrefix(u) {
+			continue
+		}
+
+		rest := ref[len(u):]
+		// The first element is the row when the unknown is a collection
+		if len(rest) > 0 {
+			if _, ok := rest[0].Value.(ast.Var); ok {
+				rest = rest[1:]
+			}
+		}
+
+		if len(rest) == 0 {
+			return "", fmt.Errorf("%q does not reference a field of %q", term, u)
+		}
+
+		path := make([]string, 0, len(rest))
+		for _, p := range rest {
+			s, ok := p.Value.(ast.String)
+			if !ok {
+				return "", fmt.Errorf("%q has a non string field %q", term, p)
+			}
+			path = append(path, string(s))
+		}
+
+		return t.columns(u.String(), path)
+	}
+
+	return "", fmt.Errorf("%q is not a reference to an unknown", term)
+}
+
+// arg appends the v to args and returns its placeholder
+func (t *Translator) arg(v interface{}, args *[]interface{}) string {
+	*args = append(*args, v)
+	return t.placeholder(len(*args))
+}
@@ -0,0 +1,109 @@
+package sqlfilter_test
+
+import (
+	"testing"
+
+	"github.com/cycloidio/gopa/sqlfilter"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslator(t *testing.T) {
+	tests := []struct {
+		name    string
+		queries []string
+		opts    []sqlfilter.OptionFunc
+		where   string
+		args    []interface{}
+	}{
+		{
+			name:  "Undefined",
+			where: sqlfilter.ClauseFalse,
+		},
+		{
+			name:    "Unconditional",
+			queries: []string{`data.resources[_].owner = "bob"`, ``},
+			where:   sqlfilter.ClauseTrue,
+		},
+		{
+			name:    "Equality",
+			queries: []string{`data.resources[_].owner = "bob"`},
+			where:   "resources.owner = ?",
+			args:    []interface{}{"bob"},
+		},
+		{
+			name:    "SwappedComparison",
+			queries: []string{`gte(4, data.resources[_].clearance_level)`},
+			where:   "resources.clearance_level <= ?",
+			args:    []interface{}{int64(4)},
+		},
+		{
+			name: "AndOr",
+			queries: []string{
+				`data.resources[_].owner = "bob"; data.resources[_].size < 2.5`,
+				`data.resources[_].public`,
+				`not data.resources[_].archived == true`,
+			},
+			where: "((resources.owner = ? AND resources.size < ?) OR resources.public = ? OR NOT (resources.archived = ?))",
+			args:  []interface{}{"bob", 2.5, true, true},
+		},
+		{
+			name:    "Null",
+			queries: []string{`data.resources[_].deleted_at == null`},
+			where:   "resources.deleted_at IS NULL",
+		},
+		{
+			name:    "Columns",
+			queries: []string{`data.resources[_].owner.name == data.resources[_].team.lead`},
+			where:   "resources.owner_name = resources.team_lead",
+		},
+		{
+			name:    "Options",
+			queries: []string{`data.resources[_].owner.name != "bob"; data.resources[_].size > 3`},
+			opts: []sqlfilter.OptionFunc{
+				sqlfilter.SetPlaceholderFunc(sqlfilter.DollarPlaceholder),
+				sqlfilter.SetColumnFunc(func(u string, p []string) (string, error) {
+					return p[len(p)-1], nil
+				}),
+			},
+			where: "(name <> $1 AND size > $2)",
+			args:  []interface{}{"bob", int64(3)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tr, err := sqlfilter.New([]string{"data.resources"}, tt.opts...)
+			require.NoError(t, err)
+
+			var res types.PartialEvaluationResultV1
+			for _, q := range tt.queries {
+				res.Queries = append(res.Queries, ast.MustParseBody(q))
+			}
+
+			where, args, err := tr.Translate(&res)
+			require.NoError(t, err)
+			assert.Equal(t, tt.where, where)
+			assert.Equal(t, tt.args, args)
+		})
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		tr, err := sqlfilter.New([]string{"data.resources"})
+		require.NoError(t, err)
+
+		for _, q := range []string{
+			`startswith(data.resources[_].owner, "b")`,
+			`data.resources[_].owner = data.users[_].name`,
+			`data.resources[_].tags = ["a"]`,
+			`data.resources[_]`,
+		} {
+			_, _, err := tr.Translate(&types.PartialEvaluationResultV1{
+				Queries: []ast.Body{ast.MustParseBody(q)},
+			})
+			assert.Error(t, err, q)
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package gopa
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// StatusService is the service in charge of
+// the Status interactions
+type StatusService struct {
+	client *Client
+	path   string
+}
+
+// NewStatusService initializes a new StatusService
+func NewStatusService(c *Client) *StatusService {
+	return &StatusService{
+		client: c,
+		path:   "/v1/status",
+	}
+}
+
+// StatusResponse models the response of the Status API
+type StatusResponse struct {
+	Result *Status `json:"result"`
+}
+
+// Status is the status report of OPA.
+// We cannot use directly the type they define as the
+// bundle status uses `Errors []error` and an interface
+// for the Metrics so it cannot be unmarshaled
+type Status struct {
+	Labels    map[string]string        `json:"labels"`
+	Bundles   map[string]*BundleStatus `json:"bundles,omitempty"`
+	Discovery *BundleStatus            `json:"discovery,omitempty"`
+	Metrics   map[string]interface{}   `json:"metrics,omitempty"`
+	Plugins   map[string]*PluginStatus `json:"plugins,omitempty"`
+}
+
+// BundleStatus is the status of a bundle
+type BundleStatus struct {
+	Name                     string                 `json:"name"`
+	ActiveRevision           string                 `json:"active_revision,omitempty"`
+	LastSuccessfulActivation time.Time              `json:"last_successful_activation,omitempty"`
+	LastSuccessfulDownload   time.Time              `json:"last_successful_download,omitempty"`
+	LastSuccessfulRequest    time.Time              `json:"last_successful_request,omitempty"`
+	LastRequest              time.Time              `json:"last_request,omitempty"`
+	Code                     string                 `json:"code,omitempty"`
+	Message                  string                 `json:"message,omitempty"`
+	Errors                   []APIError             `json:"errors,omitempty"`
+	Metrics                  map[string]interface{} `json:"metrics,omitempty"`
+}
+
+// Activated returns if the bundle has been activated at least once
+func (bs *BundleStatus) Activated() bool {
+	return !bs.LastSuccessfulActivation.IsZero()
+}
+
+// PluginStatus is the status of a plugin
+type PluginStatus struct {
+	State   string `json:"state"`
+	Message string `json:"message,omitempty"`
+}
+
+// Get returns the status of OPA, it requires the status
+// plugin to be enabled on OPA
+// https://www.openpolicyagent.org/docs/latest/rest-api/#status-api
+func (ss *StatusService) Get(ctx context.Context) (*StatusResponse, error) {
+	var res StatusResponse
+
+	err := ss.client.do(ctx, http.MethodGet, ss.path, noBody, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
@@ -0,0 +1,74 @@
+package gopa_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatusService(t *testing.T) {
+	t.Run("Get", func(t *testing.T) {
+		// The Status API is not available on all the
+		// OPA versions so we fake it
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, http.MethodGet, r.Method)
+			assert.Equal(t, "/v1/status", r.URL.Path)
+			w.Write([]byte(`{
+  "result": {
+    "labels": {"id": "opa-1"},
+    "bundles": {
+      "authz": {
+        "name": "authz",
+        "active_revision": "rev-42",
+        "last_successful_download": "2020-09-04T10:12:06.2Z",
+        "last_successful_activation": "2020-09-04T10:12:06.3Z",
+        "metrics": {"timer_rego_data_parse_ns": 12345}
+      },
+      "pending": {
+        "name": "pending",
+        "code": "bundle_error",
+        "message": "server replied with Not Found",
+        "errors": [{"code": "rego_parse_error", "message": "unexpected eof token"}]
+      }
+    },
+    "plugins": {
+      "bundle": {"state": "OK"},
+      "status": {"state": "NOT_READY"}
+    }
+  }
+}`))
+		}))
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL))
+		require.NoError(t, err)
+
+		ctx := context.Background()
+
+		res, err := c.StatusGet(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, res.Result)
+		assert.Equal(t, "opa-1", res.Result.Labels["id"])
+
+		authz := res.Result.Bundles["authz"]
+		require.NotNil(t, authz)
+		assert.True(t, authz.Activated())
+		assert.Equal(t, "rev-42", authz.ActiveRevision)
+		assert.Equal(t, time.Date(2020, 9, 4, 10, 12, 6, 200000000, time.UTC), authz.LastSuccessfulDownload)
+
+		pending := res.Result.Bundles["pending"]
+		require.NotNil(t, pending)
+		assert.False(t, pending.Activated())
+		require.Len(t, pending.Errors, 1)
+		assert.Equal(t, "rego_parse_error", pending.Errors[0].Code)
+
+		assert.Equal(t, "OK", res.Result.Plugins["bundle"].State)
+		assert.Equal(t, "NOT_READY", res.Result.Plugins["status"].State)
+	})
+}
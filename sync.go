@@ -0,0 +1,193 @@
+package gopa
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// PolicyExt is the extension of the policy
+// files loaded by LoadPolicies
+const PolicyExt = ".rego"
+
+// LoadPolicies returns the content of all the .rego files of the
+// fsys by ID, which is the relative path of the file
+func LoadPolicies(fsys fs.FS) (map[string][]byte, error) {
+	policies := make(map[string][]byte)
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || path.Ext(p) != PolicyExt {
+			return nil
+		}
+
+		b, err := fs.ReadFile(fsys, p)
+		if err != nil {
+			return err
+		}
+		policies[p] = b
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return policies, nil
+}
+
+// LoadPoliciesDir is the same as LoadPolicies but from the directory dir
+func LoadPoliciesDir(dir string) (map[string][]byte, error) {
+	return LoadPolicies(os.DirFS(dir))
+}
+
+// SyncPlan are the changes needed to make
+// OPA match the desired policies
+type SyncPlan struct {
+	// Create are the IDs of the policies that OPA does not have
+	Create []string
+	// Update are the IDs of the policies with a different content
+	Update []string
+	// Delete are the IDs of the policies that are not desired
+	Delete []string
+}
+
+// Empty returns if there are no changes
+func (p *SyncPlan) Empty() bool {
+	return len(p.Create) == 0 && len(p.Update) == 0 && len(p.Delete) == 0
+}
+
+// String returns the plan with one change per line
+// like '+ id', '~ id' and '- id'
+func (p *SyncPlan) String() string {
+	var sb strings.Builder
+	for _, ch := range []struct {
+		sign string
+		ids  []string
+	}{{"+", p.Create}, {"~", p.Update}, {"-", p.Delete}} {
+		for _, id := range ch.ids {
+			fmt.Fprintf(&sb, "%s %s\n", ch.sign, id)
+		}
+	}
+	return sb.String()
+}
+
+// syncOptions are the options of SyncPolicies
+type syncOptions struct {
+	dryRun bool
+	prefix string
+}
+
+// SyncOptionFunc is a type used to configure the SyncPolicies
+type SyncOptionFunc func(*syncOptions)
+
+// WithDryRun only returns the SyncPlan without applying it
+func WithDryRun() SyncOptionFunc {
+	return func(o *syncOptions) {
+		o.dryRun = true
+	}
+}
+
+// WithIDPrefix adds the prefix to the IDs of the desired policies and
+// only the policies of OPA with it are managed, so the ones with other
+// IDs are never deleted
+func WithIDPrefix(prefix string) SyncOptionFunc {
+	return func(o *syncOptions) {
+		o.prefix = prefix
+	}
+}
+
+// SyncPolicies creates, updates and deletes the policies of s so they
+// are exactly the desired ones, by ID. The desired policies can be loaded
+// with LoadPolicies. Like UploadPolicies, the changes are applied in the
+// order of the dependencies once it's checked locally that the result
+// compiles. It returns the SyncPlan applied, and if it failed while
+// applying it the changes before the error are already applied
+func SyncPolicies(ctx context.Context, s Service, desired map[string][]byte, opts ...SyncOptionFunc) (*SyncPlan, error) {
+	var o syncOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	pl, err := s.PolicyList(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	current := make(map[string]string, len(pl.Result))
+	for _, p := range pl.Result {
+		if strings.HasPrefix(p.ID, o.prefix) {
+			current[p.ID] = p.Raw
+		}
+	}
+
+	policies := make(map[string][]byte, len(desired))
+	for id, b := range desired {
+		policies[o.prefix+id] = b
+	}
+
+	var plan SyncPlan
+	for id, b := range policies {
+		raw, ok := current[id]
+		if !ok {
+			plan.Create = append(plan.Create, id)
+		} else if raw != string(b) {
+			plan.Update = append(plan.Update, id)
+		}
+	}
+	for id := range current {
+		if _, ok := policies[id]; !ok {
+			plan.Delete = append(plan.Delete, id)
+		}
+	}
+	sort.Strings(plan.Create)
+	sort.Strings(plan.Update)
+	sort.Strings(plan.Delete)
+
+	puts := make(map[string][]byte, len(plan.Create)+len(plan.Update))
+	for _, ids := range [][]string{plan.Create, plan.Update} {
+		for _, id := range ids {
+			puts[id] = policies[id]
+		}
+	}
+
+	modules, err := parsePolicies(puts)
+	if err != nil {
+		return &plan, err
+	}
+
+	putOrder, err := sortModules(modules)
+	if err != nil {
+		return &plan, err
+	}
+
+	delOrder, err := deleteOrder(ctx, s, plan.Delete)
+	if err != nil {
+		return &plan, err
+	}
+
+	err = checkPolicies(ctx, s, modules, puts, plan.Delete)
+	if err != nil {
+		return &plan, err
+	}
+
+	if o.dryRun {
+		return &plan, nil
+	}
+
+	// The deletes are done at the end so the policies
+	// that depended on them are already updated
+	if _, err := putPolicies(ctx, s, putOrder, puts); err != nil {
+		return &plan, err
+	}
+	if _, err := deletePolicies(ctx, s, delOrder); err != nil {
+		return &plan, err
+	}
+
+	return &plan, nil
+}
@@ -0,0 +1,115 @@
+package gopa_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadPolicies(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "authz"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "main.rego"), []byte("package main\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "authz", "users.rego"), []byte("package authz.users\n"), 0644))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("# Policies\n"), 0644))
+
+	policies, err := gopa.LoadPoliciesDir(dir)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"main.rego":        []byte("package main\n"),
+		"authz/users.rego": []byte("package authz.users\n"),
+	}, policies)
+
+	_, err = gopa.LoadPoliciesDir(filepath.Join(dir, "potato"))
+	assert.Error(t, err)
+}
+
+func TestSyncPolicies(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := gopa.NewEmbeddedClient()
+	require.NoError(t, err)
+
+	_, err = c.PolicyCreateOrUpdate(ctx, "policies/stale.rego", []byte("package stale\n"))
+	require.NoError(t, err)
+	_, err = c.PolicyCreateOrUpdate(ctx, "policies/authz/users.rego", []byte("package authz.users\n\nallow = false\n"))
+	require.NoError(t, err)
+	_, err = c.PolicyCreateOrUpdate(ctx, "policies/main.rego", []byte("package main\n"))
+	require.NoError(t, err)
+	_, err = c.PolicyCreateOrUpdate(ctx, "other", []byte("package other\n"))
+	require.NoError(t, err)
+
+	desired, err := gopa.LoadPolicies(fstest.MapFS{
+		"main.rego":        {Data: []byte("package main\n")},
+		"authz/users.rego": {Data: []byte("package authz.users\n\nallow = true\n")},
+		"authz/admin.rego": {Data: []byte("package authz.admin\n")},
+	})
+	require.NoError(t, err)
+
+	t.Run("DryRun", func(t *testing.T) {
+		plan, err := gopa.SyncPolicies(ctx, c, desired, gopa.WithIDPrefix("policies/"), gopa.WithDryRun())
+		require.NoError(t, err)
+		assert.Equal(t, &gopa.SyncPlan{
+			Create: []string{"policies/authz/admin.rego"},
+			Update: []string{"policies/authz/users.rego"},
+			Delete: []string{"policies/stale.rego"},
+		}, plan)
+		assert.Equal(t, "+ policies/authz/admin.rego\n~ policies/authz/users.rego\n- policies/stale.rego\n", plan.String())
+
+		res, err := c.PolicyList(ctx)
+		require.NoError(t, err)
+		assert.Len(t, res.Result, 4, "Nothing is changed")
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		plan, err := gopa.SyncPolicies(ctx, c, desired, gopa.WithIDPrefix("policies/"))
+		require.NoError(t, err)
+		assert.False(t, plan.Empty())
+
+		res, err := c.DataGet(ctx, "authz/users/allow")
+		require.NoError(t, err)
+		assert.Equal(t, true, *res.Result)
+
+		_, err = c.PolicyGet(ctx, "policies/stale.rego")
+		assert.True(t, gopa.IsNotFound(err))
+
+		_, err = c.PolicyGet(ctx, "other")
+		assert.NoError(t, err, "The policies without the prefix are not managed")
+
+		plan, err = gopa.SyncPolicies(ctx, c, desired, gopa.WithIDPrefix("policies/"))
+		require.NoError(t, err)
+		assert.True(t, plan.Empty())
+	})
+
+	t.Run("MutualReferences", func(t *testing.T) {
+		c, err := gopa.NewEmbeddedClient()
+		require.NoError(t, err)
+
+		_, err = gopa.SyncPolicies(ctx, c, map[string][]byte{
+			"a": []byte("package a\n\nx = data.b.y\n\nw = 1\n"),
+			"b": []byte("package b\n\ny = data.a.w\n"),
+		})
+		require.NoError(t, err)
+
+		res, err := c.DataGet(ctx, "a/x")
+		require.NoError(t, err)
+		assert.Equal(t, float64(1), *res.Result)
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		plan, err := gopa.SyncPolicies(ctx, c, map[string][]byte{"broken.rego": []byte("potato")})
+		require.Error(t, err)
+		assert.True(t, gopa.IsCompileError(err))
+		assert.Equal(t, []string{"broken.rego"}, plan.Create)
+
+		_, err = c.PolicyGet(ctx, "other")
+		assert.NoError(t, err, "The deletes are not done")
+	})
+}
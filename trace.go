@@ -0,0 +1,286 @@
+package gopa
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/server/types"
+	"github.com/open-policy-agent/opa/topdown"
+	"github.com/open-policy-agent/opa/topdown/lineage"
+)
+
+// Trace is the explanation of an evaluation returned
+// by OPA when the explain is enabled
+type Trace []*TraceEvent
+
+// TraceEvent is a step of the evaluation.
+// We cannot use directly the type they define as it
+// does not decode the Message nor the Location
+type TraceEvent struct {
+	Op       string           `json:"op"`
+	QueryID  uint64           `json:"query_id"`
+	ParentID uint64           `json:"parent_id"`
+	Type     string           `json:"type"`
+	Node     ast.Node         `json:"node"`
+	Locals   types.BindingsV1 `json:"locals"`
+	Message  string           `json:"message,omitempty"`
+	Location *Location        `json:"location,omitempty"`
+}
+
+// UnmarshalJSON decodes the TraceEvent and the Node
+// depending on the Type of the event
+func (te *TraceEvent) UnmarshalJSON(b []byte) error {
+	var raw struct {
+		Op       string           `json:"op"`
+		QueryID  uint64           `json:"query_id"`
+		ParentID uint64           `json:"parent_id"`
+		Type     string           `json:"type"`
+		Node     json.RawMessage  `json:"node"`
+		Locals   types.BindingsV1 `json:"locals"`
+		Message  string           `json:"message"`
+		Location *Location        `json:"location"`
+	}
+
+	err := json.Unmarshal(b, &raw)
+	if err != nil {
+		return err
+	}
+
+	*te = TraceEvent{
+		Op:       raw.Op,
+		QueryID:  raw.QueryID,
+		ParentID: raw.ParentID,
+		Type:     raw.Type,
+		Locals:   raw.Locals,
+		Message:  raw.Message,
+		Location: raw.Location,
+	}
+
+	if len(raw.Node) == 0 {
+		return nil
+	}
+
+	switch raw.Type {
+	case "body":
+		var body ast.Body
+		err = json.Unmarshal(raw.Node, &body)
+		te.Node = body
+	case "expr":
+		var expr ast.Expr
+		err = json.Unmarshal(raw.Node, &expr)
+		te.Node = &expr
+	case "rule":
+		var rule ast.Rule
+		err = json.Unmarshal(raw.Node, &rule)
+		te.Node = &rule
+	}
+
+	return err
+}
+
+// DecodeTrace decodes the explanation of a response, it
+// returns nil if there is no explanation. The explanation has
+// to be requested without the WithPretty option
+func DecodeTrace(t types.TraceV1) (Trace, error) {
+	if len(t) == 0 {
+		return nil, nil
+	}
+
+	var tr Trace
+	err := json.Unmarshal(t, &tr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid explanation: %w", err)
+	}
+
+	return tr, nil
+}
+
+// Notes returns only the Note events with the enclosing
+// rules and queries needed to understand where they come from
+func (t Trace) Notes() Trace {
+	return t.filter(lineage.Notes)
+}
+
+// Fails returns only the Fail events with the enclosing
+// rules and queries needed to understand where they come from
+func (t Trace) Fails() Trace {
+	return t.filter(lineage.Fails)
+}
+
+// filter applies the f to the events of t and returns
+// the TraceEvents that f kept
+func (t Trace) filter(f func([]*topdown.Event) []*topdown.Event) Trace {
+	events := t.events()
+
+	origin := make(map[*topdown.Event]*TraceEvent, len(events))
+	for i, e := range events {
+		origin[e] = t[i]
+	}
+
+	var res Trace
+	for _, e := range f(events) {
+		res = append(res, origin[e])
+	}
+
+	return res
+}
+
+// Pretty writes the human readable trace to w, with the
+// same format of 'opa eval --explain'. The location column
+// is only present if OPA returned the locations
+func (t Trace) Pretty(w io.Writer) {
+	var width int
+	for _, te := range t {
+		if l := len(te.location()); l > width {
+			width = l
+		}
+	}
+
+	depths := make(map[uint64]int)
+	paths := make(map[string]ast.Ref)
+	for _, te := range t {
+		depth := depths[te.QueryID]
+		if depth == 0 {
+			depth = depths[te.ParentID] + 1
+			depths[te.QueryID] = depth
+		}
+
+		line := te.format(depth, paths)
+		if width == 0 {
+			fmt.Fprintln(w, line)
+		} else {
+			fmt.Fprintf(w, "%-*s %s\n", width+4, te.location(), line)
+		}
+	}
+}
+
+// String returns the human readable trace
+func (t Trace) String() string {
+	var buff bytes.Buffer
+	t.Pretty(&buff)
+	return buff.String()
+}
+
+// ops are the possible trace operations
+// indexed by the OPA API representation
+var ops = map[string]topdown.Op{
+	strings.ToLower(string(topdown.EnterOp)): topdown.EnterOp,
+	strings.ToLower(string(topdown.ExitOp)):  topdown.ExitOp,
+	strings.ToLower(string(topdown.EvalOp)):  topdown.EvalOp,
+	strings.ToLower(string(topdown.RedoOp)):  topdown.RedoOp,
+	strings.ToLower(string(topdown.SaveOp)):  topdown.SaveOp,
+	strings.ToLower(string(topdown.FailOp)):  topdown.FailOp,
+	strings.ToLower(string(topdown.NoteOp)):  topdown.NoteOp,
+	strings.ToLower(string(topdown.IndexOp)): topdown.IndexOp,
+}
+
+// op returns the operation as formatted by OPA
+func (te *TraceEvent) op() topdown.Op {
+	if op, ok := ops[te.Op]; ok {
+		return op
+	}
+	return topdown.Op(te.Op)
+}
+
+// location returns the location as 'file:row'
+// or 'query:row' if the file is empty
+func (te *TraceEvent) location() string {
+	if te.Location == nil {
+		return ""
+	}
+	if te.Location.File == "" {
+		return fmt.Sprintf("query:%d", te.Location.Row)
+	}
+	return fmt.Sprintf("%s:%d", te.Location.File, te.Location.Row)
+}
+
+// format returns the event indented with the depth. The
+// paths are the references seen on previous events and are
+// used to print the full path of the rules, as the API does
+// not return the module of the rule
+func (te *TraceEvent) format(depth int, paths map[string]ast.Ref) string {
+	spaces := depth + 1
+	switch te.op() {
+	case topdown.EnterOp:
+		spaces = depth
+	case topdown.RedoOp:
+		if _, ok := te.Node.(*ast.Expr); !ok {
+			spaces = depth
+		}
+	}
+
+	var padding string
+	if spaces > 1 {
+		padding = strings.Repeat("| ", spaces-1)
+	}
+
+	if te.Node != nil {
+		ast.WalkRefs(te.Node, func(r ast.Ref) bool {
+			for i := 1; i < len(r) && r[0].Equal(ast.DefaultRootDocument); i++ {
+				s, ok := r[i].Value.(ast.String)
+				if !ok {
+					break
+				}
+				paths[string(s)] = r[:i+1]
+			}
+			return false
+		})
+	}
+
+	if te.op() == topdown.NoteOp {
+		return fmt.Sprintf("%s%s %q", padding, te.op(), te.Message)
+	} else if te.Message != "" {
+		return fmt.Sprintf("%s%s %v %s", padding, te.op(), te.Node, te.Message)
+	}
+
+	if r, ok := te.Node.(*ast.Rule); ok {
+		if r.Module != nil {
+			return fmt.Sprintf("%s%s %v", padding, te.op(), r.Path())
+		}
+		if p, ok := paths[string(r.Head.Name)]; ok {
+			return fmt.Sprintf("%s%s %v", padding, te.op(), p)
+		}
+		return fmt.Sprintf("%s%s %v", padding, te.op(), r.Head.Name)
+	}
+
+	return fmt.Sprintf("%s%s %v", padding, te.op(), te.Node)
+}
+
+// events converts the Trace to the topdown events
+// so we can use the OPA filters on them
+func (t Trace) events() []*topdown.Event {
+	events := make([]*topdown.Event, 0, len(t))
+	for _, te := range t {
+		e := &topdown.Event{
+			Op:       te.op(),
+			Node:     te.Node,
+			QueryID:  te.QueryID,
+			ParentID: te.ParentID,
+			Message:  te.Message,
+			Locals:   ast.NewValueMap(),
+		}
+
+		if te.Location != nil {
+			e.Location = &ast.Location{
+				File: te.Location.File,
+				Row:  te.Location.Row,
+				Col:  te.Location.Col,
+			}
+		}
+
+		for _, l := range te.Locals {
+			if l == nil || l.Key == nil || l.Value == nil {
+				continue
+			}
+			e.Locals.Put(l.Key.Value, l.Value.Value)
+		}
+
+		events = append(events, e)
+	}
+
+	return events
+}
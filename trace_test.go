@@ -0,0 +1,111 @@
+package gopa_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrace(t *testing.T) {
+	policyID := "example-trace"
+	policy := []byte(`
+package opa.examples
+
+allow_request { input.example.flag == true }
+
+deny_request {
+	trace("checking allow_request")
+	not allow_request
+}
+`)
+
+	c, err := gopa.NewClient()
+	require.NoError(t, err)
+
+	ctx := context.Background()
+
+	_, err = c.PolicyCreateOrUpdate(ctx, policyID, policy)
+	require.NoError(t, err)
+
+	defer c.PolicyDelete(ctx, policyID)
+
+	input := map[string]interface{}{
+		"example": map[string]interface{}{
+			"flag": false,
+		},
+	}
+
+	res, err := c.DataGetWithInput(ctx, "/opa/examples/deny_request", input, gopa.WithExplain(types.ExplainFullV1))
+	require.NoError(t, err)
+
+	tr, err := gopa.DecodeTrace(res.Explanation)
+	require.NoError(t, err)
+	require.NotEmpty(t, tr)
+
+	t.Run("DecodeTrace", func(t *testing.T) {
+		assert.Equal(t, "enter", tr[0].Op)
+		assert.Equal(t, "body", tr[0].Type)
+		assert.IsType(t, ast.Body{}, tr[0].Node)
+	})
+
+	t.Run("Pretty", func(t *testing.T) {
+		assert.Equal(t, `Enter data.opa.examples.deny_request = _
+| Eval data.opa.examples.deny_request = _
+| Index data.opa.examples.deny_request = _ (matched 1 rule)
+| Enter data.opa.examples.deny_request
+| | Eval trace("checking allow_request")
+| | Note "checking allow_request"
+| | Eval not data.opa.examples.allow_request
+| | Enter data.opa.examples.allow_request
+| | | Eval data.opa.examples.allow_request
+| | | Index data.opa.examples.allow_request matched 0 rules)
+| | | Fail data.opa.examples.allow_request
+| | Exit data.opa.examples.deny_request
+| Exit data.opa.examples.deny_request = _
+Redo data.opa.examples.deny_request = _
+| Redo data.opa.examples.deny_request = _
+| Redo data.opa.examples.deny_request
+| | Redo trace("checking allow_request")
+`, tr.String())
+	})
+
+	t.Run("Notes", func(t *testing.T) {
+		notes := tr.Notes()
+		require.Len(t, notes, 3)
+		assert.Equal(t, "checking allow_request", notes[2].Message)
+		assert.Equal(t, `Enter data.opa.examples.deny_request = _
+| Enter data.opa.examples.deny_request
+| | Note "checking allow_request"
+`, notes.String())
+	})
+
+	t.Run("Fails", func(t *testing.T) {
+		fails := tr.Fails()
+		require.NotEmpty(t, fails)
+		assert.Equal(t, "fail", fails[len(fails)-1].Op)
+	})
+
+	t.Run("Location", func(t *testing.T) {
+		tr, err := gopa.DecodeTrace(types.TraceV1(`[
+{"op":"enter","query_id":0,"parent_id":0,"type":"body","node":[{"index":0,"terms":{"type":"boolean","value":true}}],"locals":[],"location":{"file":"","row":1,"col":1}},
+{"op":"note","query_id":0,"parent_id":0,"type":"expr","node":{"index":0,"terms":{"type":"boolean","value":true}},"locals":[],"message":"hi","location":{"file":"policy.rego","row":12,"col":3}}
+]`))
+		require.NoError(t, err)
+		require.Len(t, tr, 2)
+		assert.Equal(t, &gopa.Location{File: "policy.rego", Row: 12, Col: 3}, tr[1].Location)
+		assert.Equal(t, `query:1            Enter true
+policy.rego:12     | Note "hi"
+`, tr.String())
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		tr, err := gopa.DecodeTrace(nil)
+		require.NoError(t, err)
+		assert.Nil(t, tr)
+	})
+}
@@ -0,0 +1,119 @@
+package gopa
+
+import (
+	"context"
+	"fmt"
+)
+
+// DataTxn stages several data changes to apply them
+// together. If any of them fails the previous ones are
+// restored so OPA is not left half-updated
+type DataTxn struct {
+	service Service
+	ops     []dataOp
+}
+
+// dataOp is a change staged on the DataTxn
+type dataOp struct {
+	path  string
+	apply func(context.Context, Service) error
+}
+
+// NewDataTxn initializes a new empty DataTxn on s
+func NewDataTxn(s Service) *DataTxn {
+	return &DataTxn{
+		service: s,
+	}
+}
+
+// Override stages the DataCreateOrOverride of the data on the path
+func (t *DataTxn) Override(path string, data interface{}) *DataTxn {
+	return t.stage(path, func(ctx context.Context, s Service) error {
+		return s.DataCreateOrOverride(ctx, path, data)
+	})
+}
+
+// Update stages the DataUpdate of the data on the path with the patch
+func (t *DataTxn) Update(path string, patch Patch) *DataTxn {
+	return t.stage(path, func(ctx context.Context, s Service) error {
+		return s.DataUpdate(ctx, path, patch)
+	})
+}
+
+// Delete stages the DataDelete of the data on the path
+func (t *DataTxn) Delete(path string) *DataTxn {
+	return t.stage(path, func(ctx context.Context, s Service) error {
+		return s.DataDelete(ctx, path)
+	})
+}
+
+// stage adds the change to the ones to commit
+func (t *DataTxn) stage(path string, apply func(context.Context, Service) error) *DataTxn {
+	t.ops = append(t.ops, dataOp{path: path, apply: apply})
+	return t
+}
+
+// TxnError is the error returned when a change of the DataTxn failed
+type TxnError struct {
+	// Step is the position of the change that failed
+	Step int
+	Path string
+	Err  error
+	// RollbackErr is the error restoring the previous changes,
+	// the data may be inconsistent if it's not nil
+	RollbackErr error
+}
+
+// Error transforms the error into a string
+func (e *TxnError) Error() string {
+	msg := fmt.Sprintf("step %d on %q failed: %s", e.Step, e.Path, e.Err)
+	if e.RollbackErr != nil {
+		msg += fmt.Sprintf(" (rollback failed: %s)", e.RollbackErr)
+	}
+	return msg
+}
+
+// Unwrap returns the error of the change so it can be used with errors.As
+func (e *TxnError) Unwrap() error {
+	return e.Err
+}
+
+// Commit applies the changes in order. Before each one it gets
+// the current value of the path, so if one fails the ones already
+// applied are restored in reverse order and a TxnError is returned.
+// The paths that overlap a policy fail with a VirtualDocumentError,
+// as their data can not be told apart from the result of the policy.
+// Other writers changing the same paths at the same time are not
+// isolated, as OPA has no transactions on the API
+func (t *DataTxn) Commit(ctx context.Context) error {
+	undos := make([]undo, 0, len(t.ops))
+	for i, op := range t.ops {
+		u, err := snapshotData(ctx, t.service, op.path)
+		if err == nil {
+			err = op.apply(ctx, t.service)
+		}
+		if err != nil {
+			return &TxnError{
+				Step:        i,
+				Path:        op.path,
+				Err:         err,
+				RollbackErr: rollback(ctx, undos),
+			}
+		}
+		undos = append(undos, u)
+	}
+
+	return nil
+}
+
+// rollback calls the undos in reverse order
+// and returns the first error
+func rollback(ctx context.Context, undos []undo) error {
+	var rerr error
+	for i := len(undos) - 1; i >= 0; i-- {
+		if err := undos[i](ctx); err != nil && rerr == nil {
+			rerr = err
+		}
+	}
+	return rerr
+}
@@ -0,0 +1,131 @@
+package gopa_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDataTxn(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := gopa.NewClient()
+	require.NoError(t, err)
+
+	initial := map[string]interface{}{
+		"users": map[string]interface{}{
+			"alice": map[string]interface{}{"admin": true},
+		},
+		"groups": map[string]interface{}{
+			"admins": []interface{}{"alice"},
+		},
+		"version": "1",
+	}
+	reset := func(t *testing.T) {
+		err := c.DataCreateOrOverride(ctx, "txn", initial)
+		require.NoError(t, err)
+	}
+	defer c.DataDelete(ctx, "txn")
+
+	get := func(t *testing.T) interface{} {
+		res, err := c.DataGet(ctx, "txn")
+		require.NoError(t, err)
+		require.NotNil(t, res.Result)
+		return *res.Result
+	}
+
+	t.Run("Commit", func(t *testing.T) {
+		reset(t)
+
+		err := gopa.NewDataTxn(c).
+			Override("txn/users/bob", map[string]interface{}{"admin": true}).
+			Update("txn/groups", gopa.Patch{}.Add(gopa.PatchPath("admins", "-"), "bob")).
+			Delete("txn/version").
+			Commit(ctx)
+		require.NoError(t, err)
+
+		assert.Equal(t, map[string]interface{}{
+			"users": map[string]interface{}{
+				"alice": map[string]interface{}{"admin": true},
+				"bob":   map[string]interface{}{"admin": true},
+			},
+			"groups": map[string]interface{}{
+				"admins": []interface{}{"alice", "bob"},
+			},
+		}, get(t))
+	})
+
+	t.Run("Rollback", func(t *testing.T) {
+		reset(t)
+
+		err := gopa.NewDataTxn(c).
+			Override("txn/users/bob", map[string]interface{}{"admin": true}).
+			Update("txn/groups", gopa.Patch{}.Add(gopa.PatchPath("admins", "-"), "bob")).
+			Delete("txn/version").
+			Override("txn/users/alice", map[string]interface{}{"admin": false}).
+			Delete("txn/potato").
+			Commit(ctx)
+		require.Error(t, err)
+
+		var tErr *gopa.TxnError
+		require.True(t, errors.As(err, &tErr))
+		assert.Equal(t, 4, tErr.Step)
+		assert.Equal(t, "txn/potato", tErr.Path)
+		assert.NoError(t, tErr.RollbackErr)
+		assert.True(t, gopa.IsNotFound(err))
+
+		assert.Equal(t, map[string]interface{}{
+			"users": map[string]interface{}{
+				"alice": map[string]interface{}{"admin": true},
+			},
+			"groups": map[string]interface{}{
+				"admins": []interface{}{"alice"},
+			},
+			"version": "1",
+		}, get(t), "All the changes are restored")
+	})
+
+	t.Run("VirtualDocument", func(t *testing.T) {
+		e, err := gopa.NewEmbeddedClient()
+		require.NoError(t, err)
+
+		_, err = e.PolicyCreateOrUpdate(ctx, "example-txn", []byte("package txn.rules\n\nallow = true\n"))
+		require.NoError(t, err)
+		err = e.DataCreateOrOverride(ctx, "txn/users", map[string]interface{}{"alice": true})
+		require.NoError(t, err)
+
+		// The rollback of txn would store the allow as data
+		err = gopa.NewDataTxn(e).
+			Override("txn/users/bob", true).
+			Override("txn", map[string]interface{}{"users": map[string]interface{}{}}).
+			Commit(ctx)
+		require.Error(t, err)
+
+		var tErr *gopa.TxnError
+		require.True(t, errors.As(err, &tErr))
+		assert.Equal(t, 1, tErr.Step)
+		assert.NoError(t, tErr.RollbackErr)
+
+		var vErr *gopa.VirtualDocumentError
+		require.True(t, errors.As(err, &vErr))
+		assert.Equal(t, "example-txn", vErr.PolicyID)
+
+		res, err := e.DataGet(ctx, "txn")
+		require.NoError(t, err)
+		assert.Equal(t, map[string]interface{}{
+			"users": map[string]interface{}{"alice": true},
+			"rules": map[string]interface{}{"allow": true},
+		}, *res.Result, "The first change is restored")
+
+		_, err = e.PolicyDelete(ctx, "example-txn")
+		require.NoError(t, err)
+
+		res, err = e.DataGet(ctx, "txn/rules")
+		require.NoError(t, err)
+		assert.Nil(t, res.Result, "The result of the policy is not stored as data")
+	})
+}
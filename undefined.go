@@ -0,0 +1,81 @@
+package gopa
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// ErrUndefined is the error matched by the UndefinedError, so
+// it can be checked with errors.Is(err, ErrUndefined)
+var ErrUndefined = errors.New("undefined document")
+
+// Warning is a warning sent by OPA on the response,
+// like when the requested path does not exist
+type Warning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// UndefinedError is the error returned by the strict requests
+// when the document on the Path is undefined
+type UndefinedError struct {
+	Path string
+	// Warning is the warning of the response, it's set when
+	// no policy or data exists on the Path, which usually
+	// means that the Path is wrong
+	Warning *Warning
+}
+
+// Error transforms the error into a string
+func (e *UndefinedError) Error() string {
+	msg := fmt.Sprintf("%s %q", ErrUndefined, e.Path)
+	if e.Warning != nil {
+		msg += fmt.Sprintf(": %s", e.Warning.Message)
+	}
+	return msg
+}
+
+// Is makes errors.Is(err, ErrUndefined) match the UndefinedError
+func (e *UndefinedError) Is(target error) bool {
+	return target == ErrUndefined
+}
+
+// IsUndefined checks if the err is due to the
+// document being undefined on a strict request
+func IsUndefined(err error) bool {
+	return errors.Is(err, ErrUndefined)
+}
+
+// WithStrictUndefined makes the request fail with an UndefinedError
+// when the document is undefined, instead of returning a response
+// without Result. It's meant for the paths that must always be
+// defined, so a missing policy or a typo is not silently ignored
+func WithStrictUndefined() RequestOptionFunc {
+	return func(o *RequestOptions) {
+		o.StrictUndefined = true
+	}
+}
+
+// Defined checks if the res has a Result, as it's
+// nil when the document is undefined
+func Defined(res *types.DataResponseV1) bool {
+	return res != nil && res.Result != nil
+}
+
+// dataResponse is the DataResponseV1 with the warning
+// that the newer versions of OPA send
+type dataResponse struct {
+	types.DataResponseV1
+	Warning *Warning `json:"warning,omitempty"`
+}
+
+// undefined returns the UndefinedError of the path p if the
+// res is undefined and the ro asks for it, or else nil
+func (res *dataResponse) undefined(p string, ro RequestOptions) error {
+	if !ro.StrictUndefined || res.Result != nil {
+		return nil
+	}
+	return &UndefinedError{Path: p, Warning: res.Warning}
+}
@@ -0,0 +1,105 @@
+package gopa_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/open-policy-agent/opa/server/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStrictUndefined(t *testing.T) {
+	ctx := context.Background()
+
+	policyID := "example-undefined"
+	policy := []byte(`
+package opa.undefined
+
+allow { input.user == "alice" }
+`)
+
+	c, err := gopa.NewClient()
+	require.NoError(t, err)
+
+	e, err := gopa.NewEmbeddedClient()
+	require.NoError(t, err)
+
+	for name, s := range map[string]gopa.Service{"Client": c, "Embedded": e} {
+		t.Run(name, func(t *testing.T) {
+			_, err := s.PolicyCreateOrUpdate(ctx, policyID, policy)
+			require.NoError(t, err)
+			defer s.PolicyDelete(ctx, policyID)
+
+			t.Run("Defined", func(t *testing.T) {
+				res, err := s.DataGetWithInput(ctx, "opa/undefined/allow", map[string]interface{}{"user": "alice"}, gopa.WithStrictUndefined())
+				require.NoError(t, err)
+				assert.True(t, gopa.Defined(res))
+				assert.Equal(t, true, *res.Result)
+			})
+
+			t.Run("NotStrict", func(t *testing.T) {
+				res, err := s.DataGetWithInput(ctx, "opa/undefined/allow", map[string]interface{}{"user": "bob"})
+				require.NoError(t, err)
+				assert.False(t, gopa.Defined(res))
+			})
+
+			t.Run("Undefined", func(t *testing.T) {
+				_, err := s.DataGetWithInput(ctx, "opa/undefined/allow", map[string]interface{}{"user": "bob"}, gopa.WithStrictUndefined())
+				require.Error(t, err)
+				assert.True(t, gopa.IsUndefined(err))
+				assert.True(t, errors.Is(err, gopa.ErrUndefined))
+
+				var uErr *gopa.UndefinedError
+				require.True(t, errors.As(err, &uErr))
+				assert.Equal(t, "opa/undefined/allow", uErr.Path)
+				assert.Nil(t, uErr.Warning, "The rule exists")
+			})
+
+			t.Run("Missing", func(t *testing.T) {
+				_, err := s.DataGet(ctx, "opa/undefined/alow", gopa.WithStrictUndefined())
+				require.Error(t, err)
+				assert.True(t, gopa.IsUndefined(err))
+
+				_, err = gopa.Decide[bool](ctx, s, "opa/undefined/alow", map[string]interface{}{"user": "alice"}, gopa.WithStrictUndefined())
+				assert.True(t, gopa.IsUndefined(err))
+			})
+		})
+	}
+
+	t.Run("EmbeddedWarning", func(t *testing.T) {
+		_, err := e.DataGet(ctx, "opa/undefined/alow", gopa.WithStrictUndefined())
+
+		var uErr *gopa.UndefinedError
+		require.True(t, errors.As(err, &uErr))
+		require.NotNil(t, uErr.Warning)
+		assert.Equal(t, types.CodeUndefinedDocument, uErr.Warning.Code)
+	})
+
+	t.Run("ClientWarning", func(t *testing.T) {
+		// The newer versions of OPA send a warning
+		// when the path does not exist
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"warning":{"code":"api_usage_warning","message":"requested path not found"}}`))
+		}))
+		defer ts.Close()
+
+		c, err := gopa.NewClient(gopa.SetURL(ts.URL))
+		require.NoError(t, err)
+
+		res, err := c.DataGet(ctx, "opa/undefined/alow")
+		require.NoError(t, err)
+		assert.False(t, gopa.Defined(res))
+
+		_, err = c.DataGet(ctx, "opa/undefined/alow", gopa.WithStrictUndefined())
+		assert.EqualError(t, err, `undefined document "opa/undefined/alow": requested path not found`)
+
+		var uErr *gopa.UndefinedError
+		require.True(t, errors.As(err, &uErr))
+		assert.Equal(t, &gopa.Warning{Code: "api_usage_warning", Message: "requested path not found"}, uErr.Warning)
+	})
+}
@@ -0,0 +1,56 @@
+package gopa
+
+import (
+	"context"
+	"errors"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/server/types"
+)
+
+// validateOptions are the options of ValidatePolicy
+type validateOptions struct {
+	service Service
+}
+
+// ValidateOptionFunc is a type used to configure the ValidatePolicy
+type ValidateOptionFunc func(*validateOptions)
+
+// WithPoliciesFrom compiles the policy together with the policies
+// of s, replacing the one with the same ID, so the references to
+// them are checked as the server would do
+func WithPoliciesFrom(s Service) ValidateOptionFunc {
+	return func(o *validateOptions) {
+		o.service = s
+	}
+}
+
+// ValidatePolicy compiles the policy with the id locally and returns
+// the same APIError, with the Location and the lines that failed, that
+// PolicyCreateOrUpdate would return, without modifying OPA
+func ValidatePolicy(ctx context.Context, id string, policy []byte, opts ...ValidateOptionFunc) error {
+	var o validateOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	mod, err := parseModule(id, policy)
+	if err != nil {
+		return err
+	}
+
+	if o.service != nil {
+		return checkPolicies(ctx, o.service, map[string]*ast.Module{id: mod}, map[string][]byte{id: policy}, nil)
+	}
+
+	_, err = compileModules(map[string]*ast.Module{id: mod}, types.CodeInvalidParameter)
+	if err != nil {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) {
+			apiErr.setSource(id, policy)
+		}
+		return err
+	}
+
+	return nil
+}
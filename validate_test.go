@@ -0,0 +1,66 @@
+package gopa_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/cycloidio/gopa"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePolicy(t *testing.T) {
+	ctx := context.Background()
+
+	c, err := gopa.NewClient()
+	require.NoError(t, err)
+
+	t.Run("Success", func(t *testing.T) {
+		err := gopa.ValidatePolicy(ctx, "example-validate", []byte("package opa.validate\n\nallow { input.user == \"admin\" }\n"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("SameAsServer", func(t *testing.T) {
+		for name, policy := range map[string]string{
+			"Parse":   "potato",
+			"Type":    "package opa.validate\n\nallow { foo(1) }\n",
+			"Unsafe":  "package opa.validate\n\nallow { x > 1 }\n",
+			"Details": "package opa.validate\n\nallow { x := }\n",
+		} {
+			t.Run(name, func(t *testing.T) {
+				err := gopa.ValidatePolicy(ctx, "example-validate", []byte(policy))
+				require.Error(t, err)
+				var localErr *gopa.APIError
+				require.True(t, errors.As(err, &localErr))
+
+				_, err = c.PolicyCreateOrUpdate(ctx, "example-validate", []byte(policy))
+				require.Error(t, err)
+				var serverErr *gopa.APIError
+				require.True(t, errors.As(err, &serverErr))
+
+				assert.Equal(t, serverErr, localErr)
+				assert.Equal(t, serverErr.Error(), localErr.Error())
+			})
+		}
+	})
+
+	t.Run("WithPoliciesFrom", func(t *testing.T) {
+		_, err := c.PolicyCreateOrUpdate(ctx, "example-validate-lib", []byte("package opa.lib\n\nis_admin(u) { u == \"admin\" }\n"))
+		require.NoError(t, err)
+		defer c.PolicyDelete(ctx, "example-validate-lib")
+
+		policy := []byte("package opa.validate\n\nallow { data.opa.lib.is_admin(input.user) }\n")
+
+		err = gopa.ValidatePolicy(ctx, "example-validate", policy)
+		require.Error(t, err)
+		assert.True(t, gopa.IsCompileError(err))
+		assert.Contains(t, err.Error(), "example-validate:3:9: rego_type_error: undefined function data.opa.lib.is_admin")
+
+		err = gopa.ValidatePolicy(ctx, "example-validate", policy, gopa.WithPoliciesFrom(c))
+		assert.NoError(t, err)
+
+		_, err = c.PolicyGet(ctx, "example-validate")
+		assert.True(t, gopa.IsNotFound(err), "OPA is not modified")
+	})
+}